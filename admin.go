@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBackupSize limits the size of uploaded backups.
+const maxBackupSize = 64 << 20
+
+// backup is the format of the admin backup and restore endpoints.
+type backup struct {
+	Cache   []store.Record `json:"cache"`
+	History []store.Record `json:"history"`
+}
+
+// adminAuth only lets requests through which present token as bearer token, a JWT granting
+// the admin scope if JWTs are validated or basic auth credentials if enabled. If token is empty
+// and no authentication is required, all requests are rejected.
+func (m *MeasureServer) adminAuth(token string) gin.HandlerFunc {
+	if token == "" && !m.authRequired() {
+		return func(ctx *gin.Context) {
+			abortWithError(ctx, http.StatusForbidden, errors.New("admin endpoints are disabled"))
+		}
+	}
+	return m.requireScope(scopeAdmin)
+}
+
+// bearerAuth only lets requests through which present token as bearer token. They may report
+// readings of all devices.
+func bearerAuth(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		got, ok := httpCredentials(ctx).bearer()
+		if !ok || !keyMatches(got, token) {
+			abortWithError(ctx, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+		ctx.Set(grantKey, grant{all: true})
+		ctx.Next()
+	}
+}
+
+func (m *MeasureServer) backupHandler(ctx *gin.Context) {
+	records, err := m.records()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	b := backup{
+		Cache:   records,
+		History: []store.Record{},
+	}
+	for _, device := range m.History.Devices() {
+		b.History = append(b.History, m.History.Range(device, time.Time{}, time.Time{})...)
+	}
+
+	ctx.Header("Content-Disposition", "attachment; filename=measure-backup.json")
+	ctx.JSON(http.StatusOK, b)
+}
+
+func (m *MeasureServer) restoreHandler(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBackupSize)
+
+	var b backup
+	if err := ctx.ShouldBindJSON(&b); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	for _, r := range b.History {
+		m.History.Add(r)
+	}
+	m.load(b.Cache)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"cache":   len(b.Cache),
+		"history": len(b.History),
+	})
+}
+
+// cacheEntry describes a cached reading in the admin cache listing.
+type cacheEntry struct {
+	Device   string    `json:"device"`
+	Received time.Time `json:"received"`
+	// ExpiresAt is unset for readings which never expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// cacheHandler lists the cached readings sorted by device with when they expire.
+func (m *MeasureServer) cacheHandler(ctx *gin.Context) {
+	items, err := m.Cache.Items()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ttls, err := m.Cache.TTLs()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	now := time.Now()
+	entries := []cacheEntry{}
+	for device, r := range items {
+		e := cacheEntry{Device: device, Received: r.Received}
+		if ttl, ok := ttls[device]; ok && ttl > 0 {
+			t := now.Add(ttl)
+			e.ExpiresAt = &t
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Device < entries[j].Device })
+	ctx.JSON(http.StatusOK, gin.H{
+		"ttl":     time.Duration(m.ttl.Load()).String(),
+		"entries": entries,
+	})
+}
+
+// purgeCacheHandler removes the cached reading of a device, keeping its history and metadata.
+func (m *MeasureServer) purgeCacheHandler(ctx *gin.Context) {
+	device := m.ids.canonical(ctx.Param("id"))
+	if err := m.Cache.Delete(device); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	m.Logger.Infof("purged cached reading of %q", device)
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+	})
+}
+
+// flushCacheHandler removes all cached readings.
+func (m *MeasureServer) flushCacheHandler(ctx *gin.Context) {
+	if err := m.Cache.Flush(); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	m.Logger.Infof("flushed cache")
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// cacheTTLHandler changes the TTL of readings cached from now on, e.g. {"ttl": "1h"}. Readings
+// already cached keep their TTL.
+func (m *MeasureServer) cacheTTLHandler(ctx *gin.Context) {
+	var req struct {
+		TTL string `json:"ttl" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if ttl <= 0 {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("ttl must be positive"))
+		return
+	}
+	if err := m.Cache.SetTTL(ttl); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	m.ttl.Store(int64(ttl))
+	m.Logger.Infof("changed cache TTL to %s", ttl)
+	ctx.JSON(http.StatusOK, gin.H{
+		"ttl": ttl.String(),
+	})
+}
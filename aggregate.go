@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAggregateBuckets limits the buckets a single aggregate request may return per metric.
+const maxAggregateBuckets = 10000
+
+// Aggregate functions.
+const (
+	aggregateAvg = "avg"
+	aggregateMin = "min"
+	aggregateMax = "max"
+)
+
+// aggregatePoint is the aggregate of the values of a metric received in [Start, Start+window).
+type aggregatePoint struct {
+	Start time.Time `json:"start"`
+	Value float64   `json:"value"`
+	Count int       `json:"count"`
+}
+
+// aggregateHandler returns the history of a device aggregated into buckets of window per metric,
+// e.g. ?device=kitchen&window=1h&fn=max. Buckets are aligned to multiples of window since the
+// Unix epoch and left out if they hold no readings. Without from, the last 24 hours up to to,
+// which defaults to now, are aggregated.
+func (m *MeasureServer) aggregateHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Device string        `form:"device"`
+		Window time.Duration `form:"window"`
+		Fn     string        `form:"fn"`
+		Metric string        `form:"metric"`
+		From   time.Time     `form:"from"`
+		To     time.Time     `form:"to"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if parsedQueryParameters.Device == "" {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("device not set"))
+		return
+	}
+	window := parsedQueryParameters.Window
+	fn, from, to, err := aggregateRange(window, parsedQueryParameters.Fn, parsedQueryParameters.From, parsedQueryParameters.To, time.Now())
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	device := m.ids.canonical(parsedQueryParameters.Device)
+	readings, err := m.history(device, from, to)
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+		"window": window.String(),
+		"fn":     fn,
+		"from":   from,
+		"to":     to,
+		"series": aggregate(readings, window, fn, parsedQueryParameters.Metric, unit),
+		"unit":   unit,
+	})
+}
+
+// aggregateRange validates the parameters of an aggregation and returns the function and time
+// range with defaults applied.
+func aggregateRange(window time.Duration, fn string, from, to, now time.Time) (string, time.Time, time.Time, error) {
+	if window <= 0 {
+		return "", from, to, errors.New("window not set")
+	}
+	switch fn {
+	case "":
+		fn = aggregateAvg
+	case aggregateAvg, aggregateMin, aggregateMax:
+	default:
+		return "", from, to, fmt.Errorf("unsupported function %q", fn)
+	}
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if !from.Before(to) {
+		return "", from, to, errors.New("from must be before to")
+	}
+	if n := to.Sub(from) / window; n > maxAggregateBuckets {
+		return "", from, to, fmt.Errorf("%d buckets exceed limit of %d, use a larger window", n, maxAggregateBuckets)
+	}
+	return fn, from, to, nil
+}
+
+// aggregate buckets the values of readings per metric, leaving out all but only if it is set.
+// Buckets are sorted by start and temperatures converted to unit.
+func aggregate(readings []store.Record, window time.Duration, fn, only, unit string) map[string][]aggregatePoint {
+	type key struct {
+		metric string
+		start  time.Time
+	}
+	points := map[key]*aggregatePoint{}
+	for _, r := range readings {
+		start := r.Received.Truncate(window)
+		for metric, v := range r.Reading.Metrics() {
+			if only != "" && metric != only {
+				continue
+			}
+			p, ok := points[key{metric, start}]
+			switch {
+			case !ok:
+				p = &aggregatePoint{Start: start, Value: v}
+				points[key{metric, start}] = p
+			case fn == aggregateMin:
+				p.Value = math.Min(p.Value, v)
+			case fn == aggregateMax:
+				p.Value = math.Max(p.Value, v)
+			default:
+				// Value holds the sum until all readings are seen.
+				p.Value += v
+			}
+			p.Count++
+		}
+	}
+
+	series := map[string][]aggregatePoint{}
+	for k, p := range points {
+		if fn == aggregateAvg {
+			p.Value /= float64(p.Count)
+		}
+		p.Value = math.Round(data.ConvertMetric(k.metric, p.Value, unit)*100) / 100
+		series[k.metric] = append(series[k.metric], *p)
+	}
+	for _, s := range series {
+		sort.Slice(s, func(i, j int) bool { return s[i].Start.Before(s[j].Start) })
+	}
+	return series
+}
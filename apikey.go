@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	readKey  = flag.String("readKey", "", "API key required to read readings, e.g. via collect or history. The write key is accepted as well. Empty leaves reading open.")
+	writeKey = flag.String("writeKey", "", "API key required to report readings, e.g. via the report endpoints and websocket. Device tokens are accepted for their device. Empty leaves reporting open for devices without token.")
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	// apiKeyParam allows devices which can only be configured with an URL, e.g. Shelly action
+	// URLs, to present a key.
+	apiKeyParam = "apikey"
+)
+
+// apiKey returns the key presented in the X-API-Key header or the apikey query parameter. Like
+// in gen1Handler, a second question mark appended by Gen1 firmwares separates parameters.
+func apiKey(ctx *gin.Context) string {
+	if key := ctx.GetHeader(apiKeyHeader); key != "" {
+		return key
+	}
+	// Malformed parameters are skipped, the others are still parsed.
+	query, _ := url.ParseQuery(strings.ReplaceAll(ctx.Request.URL.RawQuery, "?", "&"))
+	return query.Get(apiKeyParam)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/finfinack/measure/store"
+)
+
+const oneDay = 24 * time.Hour
+
+// archiveLoop periodically moves readings older than age from the persistent store to the
+// archive, one object per device and day.
+func (m *MeasureServer) archiveLoop(age, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.archiveOld(time.Now().Add(-age).Truncate(oneDay)); err != nil {
+			m.Logger.Warnf("unable to archive readings: %s", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (m *MeasureServer) archiveOld(cutoff time.Time) error {
+	ranger, ok := m.Store.(store.Ranger)
+	if !ok {
+		return errors.New("store does not keep history")
+	}
+	deleter, ok := m.Store.(store.Deleter)
+	if !ok {
+		return errors.New("store does not support deleting readings")
+	}
+	latest, err := m.Store.Latest()
+	if err != nil {
+		return err
+	}
+
+	before := cutoff.Add(-time.Nanosecond)
+	for _, l := range latest {
+		records, err := ranger.Range(l.Device, time.Time{}, before)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		byDay := map[time.Time][]store.Record{}
+		for _, r := range records {
+			d := r.Received.UTC().Truncate(oneDay)
+			byDay[d] = append(byDay[d], r)
+		}
+		for d, records := range byDay {
+			if err := m.Archive.Upload(l.Device, d, records); err != nil {
+				return err
+			}
+		}
+		// Only delete what was archived, readings may have arrived in the meantime.
+		if err := deleter.DeleteRange(l.Device, records[0].Received, records[len(records)-1].Received); err != nil {
+			return err
+		}
+		m.Logger.Infof("archived %d readings of %q", len(records), l.Device)
+	}
+	return nil
+}
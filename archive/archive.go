@@ -0,0 +1,172 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	dayFormat = "2006-01-02"
+	timeout   = time.Minute
+)
+
+// Archive keeps old readings in an S3 compatible bucket as one gzipped JSON lines object per
+// device and day: <prefix>/<device>/<YYYY-MM-DD>.jsonl.gz
+type Archive struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func New(endpoint, accessKey, secretKey, bucket, prefix string, useTLS bool) (*Archive, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useTLS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up S3 client: %s", err)
+	}
+	return &Archive{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+func (a *Archive) deviceDir(device string) string {
+	return path.Join(a.prefix, url.PathEscape(device)) + "/"
+}
+
+func (a *Archive) key(device string, day time.Time) string {
+	return a.deviceDir(device) + day.UTC().Format(dayFormat) + ".jsonl.gz"
+}
+
+// Upload archives the readings of a device received on the given (UTC) day, merging them with
+// readings archived for that day before.
+func (a *Archive) Upload(device string, day time.Time, records []store.Record) error {
+	existing, err := a.fetch(a.key(device, day))
+	if err != nil {
+		return err
+	}
+	h := store.NewHistory(len(existing) + len(records))
+	for _, r := range existing {
+		h.Add(r)
+	}
+	for _, r := range records {
+		h.Add(r)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, r := range h.Range(device, time.Time{}, time.Time{}) {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = a.client.PutObject(ctx, a.bucket, a.key(device, day), &buf, int64(buf.Len()), minio.PutObjectOptions{
+		ContentType:     "application/jsonl",
+		ContentEncoding: "gzip",
+	})
+	return err
+}
+
+// Range returns the archived readings of a device received in [from, to], oldest first. Zero
+// values for from or to leave the respective side of the range open.
+func (a *Archive) Range(device string, from, to time.Time) ([]store.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var keys []string
+	for obj := range a.client.ListObjects(ctx, a.bucket, minio.ListObjectsOptions{Prefix: a.deviceDir(device)}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		day, err := time.Parse(dayFormat, strings.TrimSuffix(path.Base(obj.Key), ".jsonl.gz"))
+		if err != nil {
+			continue
+		}
+		if !from.IsZero() && day.Add(24*time.Hour).Before(from) {
+			continue
+		}
+		if !to.IsZero() && day.After(to) {
+			continue
+		}
+		keys = append(keys, obj.Key)
+	}
+
+	// Keys are listed in lexical and thus chronological order.
+	records := []store.Record{}
+	for _, key := range keys {
+		archived, err := a.fetch(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range archived {
+			if (!from.IsZero() && r.Received.Before(from)) || (!to.IsZero() && r.Received.After(to)) {
+				continue
+			}
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// fetch returns the readings archived in key or none if it does not exist.
+func (a *Archive) fetch(key string) ([]store.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	obj, err := a.client.GetObject(ctx, a.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	if _, err := obj.Stat(); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(obj)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var records []store.Record
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var r store.Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("corrupt archive %q: %s", key, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// grantKey is the key of the grant of a request on the context.
+const grantKey = "grant"
+
+// authCredentials are what a request presents to authenticate, taken from HTTP headers or gRPC
+// metadata.
+type authCredentials struct {
+	// authorization is the value of the Authorization header.
+	authorization string
+	// key is the API key or device token, see apiKey.
+	key string
+}
+
+func httpCredentials(ctx *gin.Context) authCredentials {
+	return authCredentials{
+		authorization: ctx.GetHeader("Authorization"),
+		key:           apiKey(ctx),
+	}
+}
+
+func (c authCredentials) bearer() (string, bool) {
+	return strings.CutPrefix(c.authorization, "Bearer ")
+}
+
+func (c authCredentials) basic() (user, password string, ok bool) {
+	encoded, ok := strings.CutPrefix(c.authorization, "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(b), ":")
+}
+
+// keyMatches returns whether got is key. Empty keys never match.
+func keyMatches(got, key string) bool {
+	return key != "" && subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1
+}
+
+// grant is what authenticated credentials may do besides reading.
+type grant struct {
+	// all is set if readings of any device may be reported.
+	all bool
+	// device is the device a device token was issued to.
+	device string
+}
+
+// authenticate checks credentials against scope. Basic auth credentials, if enabled, are valid
+// for all scopes and JWTs, if validated, for the scopes they grant. Otherwise the admin scope
+// requires -adminToken as bearer token, the write scope -writeKey or a device token and the
+// read scope -readKey or -writeKey. Scopes without any key configured are open unless
+// authentication is required. Returns the HTTP status to reject requests with if credentials
+// aren't valid.
+func (m *MeasureServer) authenticate(c authCredentials, scope string) (grant, int, error) {
+	if user, password, ok := c.basic(); ok && m.basicAuth != nil {
+		if !m.basicAuth.valid(user, password) {
+			return grant{}, http.StatusUnauthorized, errors.New("invalid credentials")
+		}
+		return grant{all: true}, http.StatusOK, nil
+	}
+	token, bearer := c.bearer()
+	switch {
+	case bearer && scope == scopeAdmin && keyMatches(token, *adminToken):
+		return grant{all: true}, http.StatusOK, nil
+	case bearer && m.jwt != nil:
+		err := m.jwt.authorize(token, scope)
+		switch {
+		case errors.Is(err, errMissingScope):
+			return grant{}, http.StatusForbidden, err
+		case err != nil:
+			return grant{}, http.StatusUnauthorized, errors.New("invalid token")
+		}
+		return grant{all: true}, http.StatusOK, nil
+	case scope == scopeAdmin:
+		return grant{}, http.StatusUnauthorized, errors.New("invalid token")
+	}
+
+	open := !m.authRequired() && (scope == scopeRead && *readKey == "" || scope == scopeWrite && *writeKey == "")
+	switch {
+	case c.key == "" && open:
+		return grant{}, http.StatusOK, nil
+	case c.key == "":
+		return grant{}, http.StatusUnauthorized, errors.New("API key not set")
+	case keyMatches(c.key, *writeKey):
+		return grant{all: true}, http.StatusOK, nil
+	case scope == scopeRead && keyMatches(c.key, *readKey):
+		return grant{}, http.StatusOK, nil
+	case scope == scopeWrite:
+		if device, ok := m.registry.tokenDevice(hashToken(c.key)); ok {
+			return grant{device: device}, http.StatusOK, nil
+		}
+	}
+	return grant{}, http.StatusUnauthorized, errors.New("invalid API key")
+}
+
+// authRequired returns whether requests have to be authenticated even if no keys are
+// configured, as JWTs are validated or basic auth is enabled.
+func (m *MeasureServer) authRequired() bool {
+	return m.jwt != nil || m.basicAuth != nil
+}
+
+// requireScope only lets requests through whose credentials are valid for scope, see
+// authenticate.
+func (m *MeasureServer) requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		g, status, err := m.authenticate(httpCredentials(ctx), scope)
+		switch {
+		case status == http.StatusUnauthorized:
+			m.abortUnauthorized(ctx, err)
+			return
+		case err != nil:
+			abortWithError(ctx, status, err)
+			return
+		}
+		ctx.Set(grantKey, g)
+		ctx.Next()
+	}
+}
+
+// abortUnauthorized aborts a request with 401, asking browsers for credentials if basic auth
+// is enabled.
+func (m *MeasureServer) abortUnauthorized(ctx *gin.Context, err error) {
+	if m.basicAuth != nil {
+		ctx.Header("WWW-Authenticate", basicAuthRealm)
+	}
+	abortWithError(ctx, http.StatusUnauthorized, err)
+}
+
+// mayReport returns whether g may report readings of device. Device tokens are only valid for
+// their device and devices with a token don't accept reports without credentials valid for all
+// devices.
+func (m *MeasureServer) mayReport(g grant, device string) bool {
+	device = m.ids.canonical(device)
+	switch {
+	case g.all:
+		return true
+	case g.device != "":
+		return g.device == device
+	}
+	return m.registry.get(device).TokenHash == ""
+}
+
+// authorized returns whether a request which passed requireScope may report readings of device,
+// see mayReport.
+func (m *MeasureServer) authorized(ctx *gin.Context, device string) bool {
+	g, _ := ctx.Get(grantKey)
+	granted, _ := g.(grant)
+	return m.mayReport(granted, device)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"os"
+)
+
+var (
+	basicAuthUser     = flag.String("basicAuthUser", "", "User which can access all read, report and admin endpoints, including the gRPC API, with HTTP basic auth. Defaults to $"+basicAuthUserEnv+".")
+	basicAuthPassword = flag.String("basicAuthPassword", "", "Password of -basicAuthUser. Defaults to $"+basicAuthPasswordEnv+" which should be preferred as flags are visible to other users of the host.")
+)
+
+const (
+	basicAuthUserEnv     = "MEASURE_BASIC_AUTH_USER"
+	basicAuthPasswordEnv = "MEASURE_BASIC_AUTH_PASSWORD"
+	basicAuthRealm       = `Basic realm="measure", charset="UTF-8"`
+)
+
+// basicCredentials are the credentials accepted with HTTP basic auth. They grant access to all
+// protected endpoints like the write key and the admin token together.
+type basicCredentials struct {
+	user     string
+	password string
+}
+
+// newBasicCredentials returns the credentials of user and password, each falling back to
+// its environment variable, or nil if neither is set.
+func newBasicCredentials(user, password string) (*basicCredentials, error) {
+	if user == "" {
+		user = os.Getenv(basicAuthUserEnv)
+	}
+	if password == "" {
+		password = os.Getenv(basicAuthPasswordEnv)
+	}
+	switch {
+	case user == "" && password == "":
+		return nil, nil
+	case user == "" || password == "":
+		return nil, errors.New("both user and password have to be set")
+	}
+	return &basicCredentials{user: user, password: password}, nil
+}
+
+// valid returns whether user and password are the credentials.
+func (c *basicCredentials) valid(user, password string) bool {
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(c.user))
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(c.password))
+	return userOK&passwordOK == 1
+}
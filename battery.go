@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/finfinack/measure/cache"
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+var batteryWindow = flag.Duration("batteryWindow", 14*24*time.Hour, "Window of battery readings the remaining battery life of a device is estimated from.")
+
+const (
+	// batteryMinSpan is how long battery readings have to span for an estimate as the level
+	// only drops by a few percent per week.
+	batteryMinSpan = 24 * time.Hour
+	// batteryReplaced is the increase in percent after which the battery counts as replaced or
+	// recharged and earlier readings are ignored.
+	batteryReplaced = 5
+)
+
+// batteryEstimate is the estimated remaining life of the battery of a device.
+type batteryEstimate struct {
+	Percent float64 `json:"percent"`
+	// DrainPerDay is the percentage the level drops by per day.
+	DrainPerDay float64 `json:"drain_per_day"`
+	// DaysRemaining is how long the battery lasts at the current drain.
+	DaysRemaining float64   `json:"days_remaining"`
+	EmptyAt       time.Time `json:"empty_at"`
+	// Since is the first reading the estimate is based on.
+	Since time.Time `json:"since"`
+}
+
+// estimateBattery fits a line through the battery levels of the records, oldest first, since the
+// battery was last replaced. It returns nil if the readings span too little time or the level
+// doesn't drop.
+func estimateBattery(records []store.Record) *batteryEstimate {
+	type point struct {
+		t time.Time
+		v float64
+	}
+	var points []point
+	for _, r := range records {
+		if r.Reading.Battery == nil {
+			continue
+		}
+		v := *r.Reading.Battery
+		if len(points) > 0 && v-points[len(points)-1].v >= batteryReplaced {
+			points = nil
+		}
+		points = append(points, point{r.Received, v})
+	}
+	if len(points) < 2 || points[len(points)-1].t.Sub(points[0].t) < batteryMinSpan {
+		return nil
+	}
+
+	// Least squares fit of the level over days since the first reading.
+	first, last := points[0], points[len(points)-1]
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		x := p.t.Sub(first.t).Hours() / 24
+		sx += x
+		sy += p.v
+		sxx += x * x
+		sxy += x * p.v
+	}
+	n := float64(len(points))
+	slope := (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	if math.IsNaN(slope) || slope >= 0 {
+		return nil
+	}
+
+	days := last.v / -slope
+	return &batteryEstimate{
+		Percent:       last.v,
+		DrainPerDay:   math.Round(-slope*100) / 100,
+		DaysRemaining: math.Round(days*10) / 10,
+		EmptyAt:       last.t.Add(time.Duration(days * float64(24*time.Hour))),
+		Since:         first.t,
+	}
+}
+
+// deviceHandler responds with the info of a device including the estimated battery life of
+// battery powered devices, which is left out if there are not enough battery readings.
+func (m *MeasureServer) deviceHandler(ctx *gin.Context) {
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	device := m.ids.canonical(ctx.Param("id"))
+	now := time.Now()
+
+	var cached *store.Record
+	r, err := m.Cache.Get(device)
+	switch {
+	case err == nil:
+		cached = &r
+	case !errors.Is(err, cache.ErrNotFound):
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if _, seen := m.seen.get(device); cached == nil && !seen && !m.registry.get(device).Registered {
+		abortWithError(ctx, http.StatusNotFound, errors.New("unknown device"))
+		return
+	}
+
+	records, err := m.history(device, now.Add(-*batteryWindow), now)
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"device":  device,
+		"info":    m.deviceInfo(device, cached, unit, now),
+		"battery": estimateBattery(records),
+		"unit":    unit,
+	})
+}
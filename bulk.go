@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/finfinack/measure/cache"
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// collectDevices responds with the readings of the given devices. Devices which are entirely
+// unknown are listed in errors instead of failing the request.
+func (m *MeasureServer) collectDevices(ctx *gin.Context, devices []string, format string, fields []string, unit string) {
+	now := time.Now()
+	status := map[string]json.RawMessage{}
+	info := map[string]deviceInfo{}
+	values := map[string]map[string]any{}
+	failed := map[string]apiError{}
+	var records []store.Record
+	for _, device := range devices {
+		device = m.ids.canonical(device)
+		r, err := m.Cache.Get(device)
+		switch {
+		case err == nil:
+			out := outputRecord(r, unit)
+			records = append(records, out)
+			status[device] = out.Payload
+			info[device] = m.deviceInfo(device, &r, unit, now)
+			if len(fields) > 0 {
+				values[device] = selectFields(info[device], &out, fields)
+			}
+		case !errors.Is(err, cache.ErrNotFound):
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		default:
+			if _, ok := m.seen.get(device); !ok && !m.registry.get(device).Registered {
+				failed[device] = newAPIError(ctx, http.StatusNotFound, errors.New("unknown device"))
+				continue
+			}
+			// The reading expired or the registered device did not report yet.
+			info[device] = m.deviceInfo(device, nil, unit, now)
+			if len(fields) > 0 {
+				values[device] = selectFields(info[device], nil, fields)
+			}
+		}
+	}
+
+	if format != "" {
+		m.writeTabular(ctx, format, "collect", records)
+		return
+	}
+	resp := gin.H{
+		"devices": status,
+		"info":    info,
+		"unit":    unit,
+	}
+	if len(fields) > 0 {
+		resp = gin.H{
+			"devices": values,
+			"unit":    unit,
+		}
+	}
+	if len(failed) > 0 {
+		resp["errors"] = failed
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
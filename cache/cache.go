@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/finfinack/measure/store"
+)
+
+var ErrNotFound = errors.New("device not found in cache")
+
+// Cache holds the latest reading of every device for a limited time.
+type Cache interface {
+	// Set caches a reading using the default TTL.
+	Set(r store.Record) error
+	// SetWithTTL caches a reading which expires after ttl.
+	SetWithTTL(r store.Record, ttl time.Duration) error
+	// Get returns the cached reading of a device or ErrNotFound.
+	Get(device string) (store.Record, error)
+	// Items returns all cached readings keyed by device.
+	Items() (map[string]store.Record, error)
+	// Delete removes the cached reading of a device. Devices without one are ignored.
+	Delete(device string) error
+	// TTLs returns the time left until the cached reading of every device expires, 0 for
+	// readings which never expire.
+	TTLs() (map[string]time.Duration, error)
+	// SetTTL changes the default TTL of readings cached from now on.
+	SetTTL(ttl time.Duration) error
+	// Flush removes all cached readings.
+	Flush() error
+	Close() error
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	ttlcache "github.com/jellydator/ttlcache/v2"
+)
+
+// Memory is a process local cache.
+type Memory struct {
+	cache *ttlcache.Cache
+}
+
+func NewMemory(ttl time.Duration) *Memory {
+	c := ttlcache.NewCache()
+	c.SetTTL(ttl)
+	return &Memory{cache: c}
+}
+
+func (m *Memory) Set(r store.Record) error {
+	return m.cache.Set(r.Device, r)
+}
+
+func (m *Memory) SetWithTTL(r store.Record, ttl time.Duration) error {
+	return m.cache.SetWithTTL(r.Device, r, ttl)
+}
+
+func (m *Memory) Get(device string) (store.Record, error) {
+	v, err := m.cache.Get(device)
+	if errors.Is(err, ttlcache.ErrNotFound) {
+		return store.Record{}, ErrNotFound
+	}
+	if err != nil {
+		return store.Record{}, err
+	}
+	return v.(store.Record), nil
+}
+
+func (m *Memory) Items() (map[string]store.Record, error) {
+	items := map[string]store.Record{}
+	for k, v := range m.cache.GetItems() {
+		items[k] = v.(store.Record)
+	}
+	return items, nil
+}
+
+func (m *Memory) Delete(device string) error {
+	if err := m.cache.Remove(device); err != nil && !errors.Is(err, ttlcache.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// TTLs returns the TTL of every cached reading. As reads extend the TTL, it is the full TTL the
+// reading was cached with.
+func (m *Memory) TTLs() (map[string]time.Duration, error) {
+	ttls := map[string]time.Duration{}
+	for _, device := range m.cache.GetKeys() {
+		_, ttl, err := m.cache.GetWithTTL(device)
+		if errors.Is(err, ttlcache.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttls[device] = ttl
+	}
+	return ttls, nil
+}
+
+func (m *Memory) SetTTL(ttl time.Duration) error {
+	return m.cache.SetTTL(ttl)
+}
+
+func (m *Memory) Flush() error {
+	return m.cache.Purge()
+}
+
+func (m *Memory) Close() error {
+	return m.cache.Close()
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisTimeout = 5 * time.Second
+
+// Redis is a cache shared between multiple instances. Readings are stored as JSON under
+// prefix+device and expire using the native Redis key expiry.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	// ttl is the default TTL in nanoseconds, it can be changed at runtime.
+	ttl atomic.Int64
+}
+
+func NewRedis(addr, password string, db int, prefix string, ttl time.Duration) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	r := &Redis{
+		client: client,
+		prefix: prefix,
+	}
+	r.ttl.Store(int64(ttl))
+	return r, nil
+}
+
+func (r *Redis) Set(rec store.Record) error {
+	return r.SetWithTTL(rec, time.Duration(r.ttl.Load()))
+}
+
+func (r *Redis) SetWithTTL(rec store.Record, ttl time.Duration) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return r.client.Set(ctx, r.prefix+rec.Device, b, ttl).Err()
+}
+
+func (r *Redis) Get(device string) (store.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	b, err := r.client.Get(ctx, r.prefix+device).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return store.Record{}, ErrNotFound
+	}
+	if err != nil {
+		return store.Record{}, err
+	}
+	var rec store.Record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return store.Record{}, err
+	}
+	return rec, nil
+}
+
+// keys returns the keys of all cached readings.
+func (r *Redis) keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, r.prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (r *Redis) Items() (map[string]store.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	keys, err := r.keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[string]store.Record{}
+	if len(keys) == 0 {
+		return items, nil
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			// Expired between SCAN and MGET.
+			continue
+		}
+		var rec store.Record
+		if err := json.Unmarshal([]byte(s), &rec); err != nil {
+			return nil, err
+		}
+		items[rec.Device] = rec
+	}
+	return items, nil
+}
+
+func (r *Redis) Delete(device string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return r.client.Del(ctx, r.prefix+device).Err()
+}
+
+func (r *Redis) TTLs() (map[string]time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	keys, err := r.keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	ttls := map[string]time.Duration{}
+	for i, cmd := range cmds {
+		switch ttl := cmd.Val(); {
+		case ttl == -2:
+			// Expired between SCAN and PTTL.
+		case ttl == -1:
+			ttls[strings.TrimPrefix(keys[i], r.prefix)] = 0
+		default:
+			ttls[strings.TrimPrefix(keys[i], r.prefix)] = ttl
+		}
+	}
+	return ttls, nil
+}
+
+func (r *Redis) SetTTL(ttl time.Duration) error {
+	r.ttl.Store(int64(ttl))
+	return nil
+}
+
+// Flush deletes the keys of all cached readings. Other keys in the database are left alone.
+func (r *Redis) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	keys, err := r.keys(ctx)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/finfinack/measure/data"
+)
+
+var calibrations stringList
+
+func init() {
+	flag.Var(&calibrations, "calibration", "Correction of a device applied on ingestion as <device>,<metric>=<offset>[,<metric>_scale=<factor>]..., e.g. shellyplusht-abc,temperature=-1.5,humidity=2. Can be repeated.")
+}
+
+// parseCalibrations parses all -calibration flags into the calibrations per device.
+func parseCalibrations(values []string) (map[string]data.Calibrations, error) {
+	devices := map[string]data.Calibrations{}
+	for _, v := range values {
+		parts := strings.Split(v, ",")
+		device := data.CanonicalID(parts[0])
+		if device == "" || len(parts) < 2 {
+			return nil, fmt.Errorf("calibration %q has no device or correction", v)
+		}
+		if _, ok := devices[device]; !ok {
+			devices[device] = data.Calibrations{}
+		}
+		for _, p := range parts[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok {
+				return nil, fmt.Errorf("calibration %q has invalid correction %q", v, p)
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("calibration %q has invalid value %q", v, value)
+			}
+			metric, scale := strings.CutSuffix(key, "_scale")
+			switch metric {
+			case data.MetricTemperature, data.MetricHumidity:
+			default:
+				return nil, fmt.Errorf("calibration %q has unsupported metric %q", v, metric)
+			}
+			c := devices[device][metric]
+			if scale {
+				c.Scale = f
+			} else {
+				c.Offset = f
+			}
+			devices[device][metric] = c
+		}
+	}
+	return devices, nil
+}
+
+// calibrate applies the calibration configured for a device to a payload received from it.
+func (m *MeasureServer) calibrate(device string, payload json.RawMessage) json.RawMessage {
+	c, ok := m.calibrations[device]
+	if !ok {
+		return payload
+	}
+	calibrated, err := data.Calibrate(payload, c)
+	if err != nil {
+		m.Logger.Warnf("unable to calibrate reading of %q: %s", device, err)
+		return payload
+	}
+	return calibrated
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net"
+	"strings"
+
+	"github.com/finfinack/measure/data"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxCoIoTPacket = 4096
+
+var (
+	coiotEnabled = flag.Bool("coiot", false, "Listen for CoIoT status publications of Gen1 Shelly devices.")
+	coiotAddr    = flag.String("coiotAddr", "224.0.1.187:5683", "Multicast group and port CoIoT publications are received on. Devices configured with a unicast CoIoT peer are received as well.")
+)
+
+// coiotPrefixes maps Gen1 device types onto the prefix of their device ID, e.g. SHHT-1 devices
+// call themselves shellyht-<id>.
+var coiotPrefixes = map[string]string{
+	"SHHT-1":   "shellyht",
+	"SHWT-1":   "shellyflood",
+	"SHDW-2":   "shellydw2",
+	"SHMOS-01": "shellymotionsensor",
+}
+
+// listenCoIoT records the readings Gen1 devices publish via CoIoT without any configuration on
+// the device.
+func (m *MeasureServer) listenCoIoT(addr string) error {
+	group, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, maxCoIoTPacket)
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				m.Logger.Warnf("unable to read CoIoT packet: %s", err)
+				return
+			}
+			m.coiot(buf[:n], src)
+		}
+	}()
+	return nil
+}
+
+func (m *MeasureServer) coiot(packet []byte, src *net.UDPAddr) {
+	status, err := data.ParseCoIoT(packet)
+	if err != nil {
+		m.Logger.Debugf("ignoring CoIoT packet from %s: %s", src, err)
+		return
+	}
+	prefix, ok := coiotPrefixes[status.Type]
+	if !ok {
+		prefix = strings.ToLower(status.Type)
+	}
+	device := prefix + "-" + status.ID
+
+	r := data.ReportStatus{Device: device}
+	for id, value := range map[int]**float64{
+		data.CoIoTTemperature: &r.Temperature,
+		data.CoIoTHumidity:    &r.Humidity,
+		data.CoIoTBattery:     &r.Battery,
+	} {
+		if v, ok := status.Values[id]; ok {
+			*value = &v
+		}
+	}
+	if r.Temperature == nil && r.Humidity == nil {
+		return
+	}
+	if err := r.Validate(); err != nil {
+		m.Logger.Warnf("dropping invalid reading of %q: %s", device, err)
+		return
+	}
+
+	ctx, span := tracer.Start(context.Background(), "coiot", trace.WithAttributes(
+		attribute.String("device", device),
+		attribute.String("source", src.String()),
+	))
+	defer span.End()
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	m.record(ctx, device, json.RawMessage(msg))
+}
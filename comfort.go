@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"sync"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+)
+
+var comfortRules stringList
+
+func init() {
+	flag.Var(&comfortRules, "comfortRule", "Rule classifying readings as <level>:<metric><op><value>[&...], where level is warning or critical and op one of <, <=, >, >=, e.g. critical:humidity>=70. Can be repeated and replaces the default rules flagging mold risk and uncomfortable rooms.")
+}
+
+// defaultComfortRules flag humid rooms where mold may grow as well as too dry, cold or warm ones.
+var defaultComfortRules = []string{
+	"warning:humidity>=60",
+	"critical:humidity>=70",
+	"warning:humidity<30",
+	"warning:temperature<16",
+	"warning:temperature>26",
+}
+
+// parseComfortRules parses the -comfortRule flags, falling back to the default rules.
+func parseComfortRules(values []string) ([]data.ComfortRule, error) {
+	if len(values) == 0 {
+		values = defaultComfortRules
+	}
+	rules := make([]data.ComfortRule, 0, len(values))
+	for _, v := range values {
+		r, err := data.ParseComfortRule(v)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// comfort tracks the comfort level of every device to raise an event when it changes.
+type comfort struct {
+	rules []data.ComfortRule
+
+	mu     sync.Mutex
+	levels map[string]string
+}
+
+func newComfort(rules []data.ComfortRule) *comfort {
+	return &comfort{
+		rules:  rules,
+		levels: map[string]string{},
+	}
+}
+
+// classify classifies a reading of a device. It returns the classification and whether the level
+// changed. Devices starting out as ok don't count as change.
+func (c *comfort) classify(r store.Record) (*data.Comfort, bool) {
+	cls := data.Classify(r.Reading.Metrics(), c.rules)
+	if cls == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.levels[r.Device]
+	c.levels[r.Device] = cls.Level
+	if !ok {
+		return cls, cls.Level != data.ComfortOK
+	}
+	return cls, prev != cls.Level
+}
+
+func (c *comfort) delete(device string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.levels, device)
+}
+
+// checkComfort records an event with component comfort whenever the comfort level of a device
+// changes so it can be alerted on via the event webhook.
+func (m *MeasureServer) checkComfort(r store.Record) {
+	cls, changed := m.comfort.classify(r)
+	if !changed {
+		return
+	}
+	e := data.Event{Device: r.Device, Component: "comfort", Event: cls.Level, Time: r.Received}
+	if len(cls.Reasons) > 0 {
+		reasons, err := json.Marshal(map[string][]string{"reasons": cls.Reasons})
+		if err != nil {
+			return
+		}
+		e.Data = reasons
+	}
+	if err := m.Events.add(e); err != nil {
+		m.Logger.Warnf("unable to forward comfort event of %q: %s", r.Device, err)
+	}
+}
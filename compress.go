@@ -0,0 +1,121 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+var compressResponses = flag.Bool("compressResponses", true, "Compress responses with brotli or gzip for clients which accept it.")
+
+// negotiateEncoding returns the encoding preferred by an Accept-Encoding header, br or gzip, or ""
+// if the client accepts neither. Brotli wins ties as it compresses JSON better.
+func negotiateEncoding(header string) string {
+	var best string
+	var bestQ float64
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "br" && name != "gzip" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		// q=0 refuses the encoding.
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses the body written by a handler. Headers are only changed once the
+// handler writes them, so responses without a body are left alone.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	w        io.WriteCloser
+}
+
+func (c *compressWriter) start() {
+	if c.w != nil {
+		return
+	}
+	c.Header().Set("Content-Encoding", c.encoding)
+	c.Header().Del("Content-Length")
+	if c.encoding == "br" {
+		c.w = brotli.NewWriter(c.ResponseWriter)
+	} else {
+		c.w = gzip.NewWriter(c.ResponseWriter)
+	}
+}
+
+func (c *compressWriter) WriteHeaderNow() {
+	if !c.Written() {
+		c.start()
+	}
+	c.ResponseWriter.WriteHeaderNow()
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	c.start()
+	return c.w.Write(b)
+}
+
+func (c *compressWriter) WriteString(s string) (int, error) {
+	return c.Write([]byte(s))
+}
+
+func (c *compressWriter) Flush() {
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	c.ResponseWriter.Flush()
+}
+
+func (c *compressWriter) close() error {
+	if c.w == nil {
+		return nil
+	}
+	return c.w.Close()
+}
+
+// compress compresses responses in the encoding negotiated with the client. Websockets and
+// streams are left alone, as are the Prometheus metrics which are compressed by their handler.
+func compress(ctx *gin.Context) {
+	switch ctx.FullPath() {
+	case wsEndpoint, subscribeEndpoint, streamEndpoint, metricsEndpoint:
+		ctx.Next()
+		return
+	}
+	ctx.Writer.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(ctx.GetHeader("Accept-Encoding"))
+	if encoding == "" || ctx.Request.Method == http.MethodHead {
+		ctx.Next()
+		return
+	}
+
+	w := &compressWriter{ResponseWriter: ctx.Writer, encoding: encoding}
+	ctx.Writer = w
+	defer func() {
+		if err := w.close(); err != nil {
+			ctx.Error(err)
+		}
+		ctx.Writer = w.ResponseWriter
+	}()
+	ctx.Next()
+}
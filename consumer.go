@@ -0,0 +1,112 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// consumerPing is the interval consumer websockets are pinged at to detect dead connections.
+const consumerPing = 30 * time.Second
+
+// subscription is sent by consumers to choose the devices whose readings they receive, e.g.
+// {"devices": ["kitchen", "bathroom"]} or {"devices": ["*"]} for all. Every subscription
+// replaces the previous one, an empty list pauses the stream.
+type subscription struct {
+	Devices []string `json:"devices"`
+	// Unit is the unit temperatures are pushed in, defaulting to the one of the server.
+	Unit string `json:"unit"`
+}
+
+// subscribeHandler serves the websocket consumers receive readings on as they arrive. Every
+// subscription is acknowledged with {"subscribed": [...], "unit": ...}, invalid ones are answered
+// with {"error": ...}.
+func (m *MeasureServer) subscribeHandler(ctx *gin.Context) {
+	c, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		m.Logger.Warnf("upgrade: %s", err)
+		return
+	}
+	defer c.Close()
+
+	readings, unsubscribe := m.hub.subscribe()
+	defer unsubscribe()
+
+	var (
+		// mu guards writes to the connection and the subscription.
+		mu      sync.Mutex
+		all     bool
+		devices []string
+		unit    string
+	)
+	write := func(v any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		c.SetWriteDeadline(time.Now().Add(consumerPing))
+		return c.WriteJSON(v)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var sub subscription
+			if err := c.ReadJSON(&sub); err != nil {
+				if _, ok := err.(*websocket.CloseError); !ok {
+					m.Logger.Debugf("consumer read: %s", err)
+				}
+				return
+			}
+			if sub.Unit == "" {
+				sub.Unit = *temperatureUnit
+			}
+			u, err := parseUnit(sub.Unit)
+			if err != nil {
+				write(gin.H{"error": err.Error()})
+				continue
+			}
+			mu.Lock()
+			all, devices, unit = slices.Contains(sub.Devices, "*"), nil, u
+			for _, d := range sub.Devices {
+				if d != "*" {
+					devices = append(devices, m.ids.canonical(d))
+				}
+			}
+			mu.Unlock()
+			subscribed := sub.Devices
+			if subscribed == nil {
+				subscribed = []string{}
+			}
+			write(gin.H{"subscribed": subscribed, "unit": u})
+		}
+	}()
+
+	ping := time.NewTicker(consumerPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ping.C:
+			mu.Lock()
+			err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(consumerPing))
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		case r := <-readings:
+			mu.Lock()
+			wanted, u := all || slices.Contains(devices, r.Device), unit
+			mu.Unlock()
+			if !wanted {
+				continue
+			}
+			if err := write(newLiveReading(r, u)); err != nil {
+				return
+			}
+		}
+	}
+}
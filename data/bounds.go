@@ -0,0 +1,62 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Bounds is the range [Min, Max] of plausible values of a metric.
+type Bounds struct {
+	Min, Max float64
+}
+
+// ParseBounds parses bounds given as <min>:<max>.
+func ParseBounds(s string) (Bounds, error) {
+	lo, hi, ok := strings.Cut(s, ":")
+	if !ok {
+		return Bounds{}, fmt.Errorf("invalid range %q, expected <min>:<max>", s)
+	}
+	var b Bounds
+	var err error
+	if b.Min, err = strconv.ParseFloat(lo, 64); err != nil {
+		return Bounds{}, fmt.Errorf("invalid range %q: %s", s, err)
+	}
+	if b.Max, err = strconv.ParseFloat(hi, 64); err != nil {
+		return Bounds{}, fmt.Errorf("invalid range %q: %s", s, err)
+	}
+	if b.Min > b.Max {
+		return Bounds{}, fmt.Errorf("invalid range %q, minimum exceeds maximum", s)
+	}
+	return b, nil
+}
+
+func (b Bounds) Contains(v float64) bool {
+	return !math.IsNaN(v) && v >= b.Min && v <= b.Max
+}
+
+func (b Bounds) Clamp(v float64) float64 {
+	return math.Max(b.Min, math.Min(b.Max, v))
+}
+
+// Implausible returns the metrics of the reading, including those of all channels, which are
+// outside of their bounds.
+func (r Reading) Implausible(bounds map[string]Bounds) []string {
+	var metrics []string
+	check := func(metric, name string, v *float64) {
+		if b, ok := bounds[metric]; ok && v != nil && !b.Contains(*v) {
+			metrics = append(metrics, name)
+		}
+	}
+	check(MetricTemperature, MetricTemperature, r.Temperature)
+	check(MetricHumidity, MetricHumidity, r.Humidity)
+	for i, c := range r.Channels {
+		if i == 0 {
+			continue
+		}
+		check(MetricTemperature, ChannelMetric(MetricTemperature, c.ID), c.Temperature)
+		check(MetricHumidity, ChannelMetric(MetricHumidity, c.ID), c.Humidity)
+	}
+	return metrics
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BLUEvent is the name of events emitted by the Shelly BLU gateway script for every BTHome
+// advertisement it receives.
+const BLUEvent = "shelly-blu"
+
+// BLUReading is a reading of a BTHome sensor such as a Shelly BLU H&T forwarded by a gateway.
+type BLUReading struct {
+	Address     string // MAC address of the sensor, lower case
+	PacketID    *int
+	Battery     *float64
+	Temperature *float64
+	Humidity    *float64
+}
+
+// BLUReadings returns the BTHome readings contained in a NotifyEvent message. Events either
+// carry the values decoded by the gateway script or the raw BTHome service data as hex string.
+func BLUReadings(payload []byte) []BLUReading {
+	var msg struct {
+		Params struct {
+			Events []struct {
+				Event string `json:"event"`
+				Data  struct {
+					Address     string   `json:"address"`
+					PacketID    *int     `json:"pid"`
+					Battery     *float64 `json:"battery"`
+					Temperature *float64 `json:"temperature"`
+					Humidity    *float64 `json:"humidity"`
+					ServiceData string   `json:"service_data"`
+				} `json:"data"`
+			} `json:"events"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil
+	}
+
+	var readings []BLUReading
+	for _, e := range msg.Params.Events {
+		if e.Event != BLUEvent || e.Data.Address == "" {
+			continue
+		}
+		r := BLUReading{
+			Address:     strings.ToLower(e.Data.Address),
+			PacketID:    e.Data.PacketID,
+			Battery:     e.Data.Battery,
+			Temperature: e.Data.Temperature,
+			Humidity:    e.Data.Humidity,
+		}
+		if e.Data.ServiceData != "" {
+			raw, err := hex.DecodeString(e.Data.ServiceData)
+			if err != nil {
+				continue
+			}
+			decoded, err := ParseBTHome(raw)
+			if err != nil {
+				continue
+			}
+			decoded.Address = r.Address
+			r = decoded
+		}
+		readings = append(readings, r)
+	}
+	return readings
+}
+
+var errBTHomeEncrypted = errors.New("encrypted BTHome payloads are not supported")
+
+// bthomeObjects holds the length and factor of the supported BTHome v2 object IDs. Parsing
+// stops at the first unknown object as its length is unknown.
+var bthomeObjects = map[byte]struct {
+	size   int
+	signed bool
+	factor float64
+}{
+	0x00: {1, false, 1},     // packet id
+	0x01: {1, false, 1},     // battery %
+	0x02: {2, true, 0.01},   // temperature °C
+	0x03: {2, false, 0.01},  // humidity %
+	0x05: {3, false, 0.01},  // illuminance lux
+	0x0c: {2, false, 0.001}, // voltage V
+	0x21: {1, false, 1},     // motion
+	0x2d: {1, false, 1},     // window
+	0x2e: {1, false, 1},     // humidity %
+	0x3a: {1, false, 1},     // button
+	0x3f: {2, true, 0.1},    // rotation °
+	0x45: {2, true, 0.1},    // temperature °C
+}
+
+// ParseBTHome decodes unencrypted BTHome v2 service data (UUID 0xfcd2).
+func ParseBTHome(raw []byte) (BLUReading, error) {
+	var r BLUReading
+	if len(raw) < 1 {
+		return r, errors.New("empty BTHome payload")
+	}
+	info := raw[0]
+	if info&0x01 != 0 {
+		return r, errBTHomeEncrypted
+	}
+	if version := info >> 5; version != 2 {
+		return r, fmt.Errorf("unsupported BTHome version %d", version)
+	}
+
+	for i := 1; i < len(raw); {
+		id := raw[i]
+		obj, ok := bthomeObjects[id]
+		if !ok {
+			break
+		}
+		if i+1+obj.size > len(raw) {
+			return r, fmt.Errorf("truncated BTHome object 0x%02x", id)
+		}
+		b := raw[i+1 : i+1+obj.size]
+		i += 1 + obj.size
+
+		var u uint32
+		for j := len(b) - 1; j >= 0; j-- {
+			u = u<<8 | uint32(b[j])
+		}
+		v := float64(u)
+		if obj.signed && obj.size == 2 {
+			v = float64(int16(binary.LittleEndian.Uint16(b)))
+		}
+		v *= obj.factor
+
+		switch id {
+		case 0x00:
+			pid := int(u)
+			r.PacketID = &pid
+		case 0x01:
+			r.Battery = &v
+		case 0x02, 0x45:
+			r.Temperature = &v
+		case 0x03, 0x2e:
+			r.Humidity = &v
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Calibration corrects the values of a sensor as value*Scale + Offset. A zero Scale is treated
+// as 1 so an offset alone can be configured.
+type Calibration struct {
+	Offset float64
+	Scale  float64
+}
+
+func (c Calibration) Apply(v float64) float64 {
+	if c.Scale != 0 {
+		v *= c.Scale
+	}
+	return v + c.Offset
+}
+
+// Calibrations holds the calibration of a device per metric.
+type Calibrations map[string]Calibration
+
+// Calibrate applies the calibrations to a payload which is either a ReportStatus or a raw
+// websocket message as sent by the device. In the latter case all temperature and humidity
+// components are corrected.
+func Calibrate(payload []byte, c Calibrations) ([]byte, error) {
+	return MapValues(payload, func(metric string, v float64) float64 {
+		if cal, ok := c[metric]; ok {
+			return cal.Apply(v)
+		}
+		return v
+	})
+}
+
+// MapValues replaces the temperatures and humidities of a payload which is either a
+// ReportStatus or a raw websocket message by the result of fn. Temperatures are in Celsius.
+func MapValues(payload []byte, fn func(metric string, v float64) float64) ([]byte, error) {
+	apply := func(metric string, v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		r := fn(metric, *v)
+		return &r
+	}
+
+	var msg WSMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Method == "" {
+		var r ReportStatus
+		if err := json.Unmarshal(payload, &r); err != nil {
+			return nil, err
+		}
+		r.Temperature = apply(MetricTemperature, r.Temperature)
+		r.Humidity = apply(MetricHumidity, r.Humidity)
+		return json.Marshal(r)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw["params"]) == 0 {
+		return payload, nil
+	}
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(raw["params"], &params); err != nil {
+		return nil, err
+	}
+	for key, value := range params {
+		name, _, _ := strings.Cut(key, ":")
+		if name != "temperature" && name != "humidity" {
+			continue
+		}
+		var component map[string]any
+		if err := json.Unmarshal(value, &component); err != nil {
+			continue
+		}
+		if tc, ok := component["tC"].(float64); ok {
+			tc = fn(MetricTemperature, tc)
+			component["tC"] = tc
+			if _, ok := component["tF"]; ok {
+				component["tF"] = CelsiusToFahrenheit(tc)
+			}
+		}
+		if rh, ok := component["rh"].(float64); ok {
+			component["rh"] = fn(MetricHumidity, rh)
+		}
+		var err error
+		if params[key], err = json.Marshal(component); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if raw["params"], err = json.Marshal(params); err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Channel holds the values of one probe of a device with multiple sensors, e.g. a Shelly with
+// addon which reports temperature:100 to temperature:104. The ID is the component ID.
+type Channel struct {
+	ID          int      `json:"id"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+}
+
+// ChannelMetric returns the name under which a metric of an additional channel is exported.
+func ChannelMetric(metric string, id int) string {
+	return metric + "_" + strconv.Itoa(id)
+}
+
+// ParseChannels returns the temperature and humidity components of a status, ordered by ID.
+func ParseChannels(params map[string]json.RawMessage) []Channel {
+	channels := map[int]*Channel{}
+	channel := func(id int) *Channel {
+		if _, ok := channels[id]; !ok {
+			channels[id] = &Channel{ID: id}
+		}
+		return channels[id]
+	}
+	for key, raw := range params {
+		component, idx, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idx)
+		if err != nil {
+			continue
+		}
+		switch component {
+		case "temperature":
+			var t TemperatureStatus
+			if json.Unmarshal(raw, &t) == nil {
+				channel(id).Temperature = t.TC
+			}
+		case "humidity":
+			var h HumidityStatus
+			if json.Unmarshal(raw, &h) == nil {
+				channel(id).Humidity = h.RH
+			}
+		}
+	}
+
+	list := make([]Channel, 0, len(channels))
+	for _, c := range channels {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// componentMetrics maps status components of other sensor types onto the field holding their
+// value and the metric it is recorded as.
+var componentMetrics = map[string]struct {
+	field  string
+	metric string
+}{
+	"illuminance": {"lux", MetricIlluminance},
+	"pressure":    {"value", MetricPressure},
+	"co2":         {"value", MetricCO2},
+	"voltmeter":   {"voltage", "voltage"},
+}
+
+// ParseComponents returns the values of status components of other sensor types than
+// temperature and humidity by metric. Components with other IDs than the lowest are named by
+// ChannelMetric.
+func ParseComponents(params map[string]json.RawMessage) map[string]float64 {
+	type value struct {
+		id int
+		v  float64
+	}
+	byMetric := map[string][]value{}
+	for key, raw := range params {
+		component, idx, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		cm, ok := componentMetrics[component]
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idx)
+		if err != nil {
+			continue
+		}
+		var fields map[string]any
+		if json.Unmarshal(raw, &fields) != nil {
+			continue
+		}
+		if v, ok := fields[cm.field].(float64); ok {
+			byMetric[cm.metric] = append(byMetric[cm.metric], value{id, v})
+		}
+	}
+
+	values := map[string]float64{}
+	for metric, vs := range byMetric {
+		sort.Slice(vs, func(i, j int) bool { return vs[i].id < vs[j].id })
+		for i, v := range vs {
+			name := metric
+			if i > 0 {
+				name = ChannelMetric(metric, v.id)
+			}
+			values[name] = v.v
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
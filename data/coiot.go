@@ -0,0 +1,129 @@
+package data
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	coapCodeCoIoT     = 30   // non-standard code used by Shelly for status publications
+	coapOptionURIPath = 11   // CoAP Uri-Path
+	coiotOptionDevice = 3332 // <type>#<id>#<coiot version>
+)
+
+// CoIoT sensor IDs (version 2) of the values measure records.
+const (
+	CoIoTTemperature = 3101 // °C
+	CoIoTHumidity    = 3103 // %
+	CoIoTBattery     = 3111 // %
+)
+
+// CoIoTStatus is a status publication of a Gen1 Shelly device.
+type CoIoTStatus struct {
+	Type   string          // e.g. SHHT-1
+	ID     string          // e.g. AABBCC
+	Values map[int]float64 // CoIoT sensor ID -> value, channel 0 only
+}
+
+// ParseCoIoT parses a CoAP packet of a Gen1 device publishing its status (path cit/s).
+func ParseCoIoT(packet []byte) (CoIoTStatus, error) {
+	var s CoIoTStatus
+	if len(packet) < 4 {
+		return s, errors.New("short CoAP packet")
+	}
+	if version := packet[0] >> 6; version != 1 {
+		return s, fmt.Errorf("unsupported CoAP version %d", version)
+	}
+	if packet[1] != coapCodeCoIoT {
+		return s, fmt.Errorf("unexpected CoAP code %d", packet[1])
+	}
+	i := 4 + int(packet[0]&0x0f) // skip token
+	if i > len(packet) {
+		return s, errors.New("truncated CoAP token")
+	}
+
+	var (
+		option int
+		path   []string
+	)
+	for i < len(packet) && packet[i] != 0xff {
+		delta, length := int(packet[i]>>4), int(packet[i]&0x0f)
+		i++
+		var err error
+		if delta, i, err = coapExtended(packet, i, delta); err != nil {
+			return s, err
+		}
+		if length, i, err = coapExtended(packet, i, length); err != nil {
+			return s, err
+		}
+		if i+length > len(packet) {
+			return s, errors.New("truncated CoAP option")
+		}
+		option += delta
+		value := string(packet[i : i+length])
+		i += length
+
+		switch option {
+		case coapOptionURIPath:
+			path = append(path, value)
+		case coiotOptionDevice:
+			parts := strings.Split(value, "#")
+			if len(parts) < 2 {
+				return s, fmt.Errorf("invalid CoIoT device option %q", value)
+			}
+			s.Type, s.ID = parts[0], parts[1]
+		}
+	}
+	if strings.Join(path, "/") != "cit/s" {
+		return s, fmt.Errorf("not a status publication: %q", strings.Join(path, "/"))
+	}
+	if s.ID == "" {
+		return s, errors.New("CoIoT status without device option")
+	}
+	if i >= len(packet) {
+		return s, errors.New("CoIoT status without payload")
+	}
+
+	var payload struct {
+		G [][]json.Number `json:"G"`
+	}
+	if err := json.Unmarshal(packet[i+1:], &payload); err != nil {
+		return s, fmt.Errorf("invalid CoIoT payload: %s", err)
+	}
+	s.Values = map[int]float64{}
+	for _, g := range payload.G {
+		if len(g) != 3 {
+			continue
+		}
+		ch, err1 := g[0].Int64()
+		id, err2 := g[1].Int64()
+		v, err3 := g[2].Float64()
+		if err1 != nil || err2 != nil || err3 != nil || ch != 0 {
+			continue
+		}
+		s.Values[int(id)] = v
+	}
+	return s, nil
+}
+
+// coapExtended resolves the extended encoding of option deltas and lengths.
+func coapExtended(packet []byte, i, v int) (int, int, error) {
+	switch v {
+	case 13:
+		if i+1 > len(packet) {
+			return 0, i, errors.New("truncated CoAP option")
+		}
+		return int(packet[i]) + 13, i + 1, nil
+	case 14:
+		if i+2 > len(packet) {
+			return 0, i, errors.New("truncated CoAP option")
+		}
+		return int(binary.BigEndian.Uint16(packet[i:])) + 269, i + 2, nil
+	case 15:
+		return 0, i, errors.New("reserved CoAP option encoding")
+	}
+	return v, i, nil
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comfort levels of a device, ordered by severity.
+const (
+	ComfortOK       = "ok"
+	ComfortWarning  = "warning"
+	ComfortCritical = "critical"
+)
+
+var comfortSeverity = map[string]int{
+	ComfortOK:       0,
+	ComfortWarning:  1,
+	ComfortCritical: 2,
+}
+
+// ComfortSeverity returns the severity of a comfort level, 0 for ok.
+func ComfortSeverity(level string) int {
+	return comfortSeverity[level]
+}
+
+// condition compares a metric to a value.
+type condition struct {
+	metric string
+	op     string
+	value  float64
+}
+
+func (c condition) matches(v float64) bool {
+	switch c.op {
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	}
+	return false
+}
+
+// ComfortRule classifies readings matching all of its conditions as Level.
+type ComfortRule struct {
+	Level      string
+	conditions []condition
+	rule       string
+}
+
+func (r ComfortRule) String() string {
+	return r.rule
+}
+
+// ParseComfortRule parses a rule given as <level>:<condition>[&<condition>]..., where every
+// condition compares a metric using <, <=, > or >=, e.g. critical:humidity>70&temperature>15.
+func ParseComfortRule(s string) (ComfortRule, error) {
+	level, expr, ok := strings.Cut(s, ":")
+	if !ok || (level != ComfortWarning && level != ComfortCritical) {
+		return ComfortRule{}, fmt.Errorf("invalid rule %q, expected warning:<condition> or critical:<condition>", s)
+	}
+	r := ComfortRule{Level: level, rule: expr}
+	for _, c := range strings.Split(expr, "&") {
+		i := strings.IndexAny(c, "<>")
+		if i <= 0 {
+			return ComfortRule{}, fmt.Errorf("invalid condition %q in rule %q", c, s)
+		}
+		cond := condition{metric: strings.TrimSpace(c[:i]), op: c[i : i+1]}
+		value := c[i+1:]
+		if strings.HasPrefix(value, "=") {
+			cond.op += "="
+			value = value[1:]
+		}
+		if !ValidMetric(cond.metric) {
+			return ComfortRule{}, fmt.Errorf("invalid metric %q in rule %q", cond.metric, s)
+		}
+		var err error
+		if cond.value, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return ComfortRule{}, fmt.Errorf("invalid value %q in rule %q", value, s)
+		}
+		r.conditions = append(r.conditions, cond)
+	}
+	return r, nil
+}
+
+// applies returns whether the metrics contain all metrics the rule compares.
+func (r ComfortRule) applies(metrics map[string]float64) bool {
+	for _, c := range r.conditions {
+		if _, ok := metrics[c.metric]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ComfortRule) matches(metrics map[string]float64) bool {
+	for _, c := range r.conditions {
+		if v, ok := metrics[c.metric]; !ok || !c.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Comfort is the classification of a reading.
+type Comfort struct {
+	Level string `json:"level"`
+	// Reasons lists the conditions of the matching rules of the level.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Classify returns the level of the most severe rule matching the metrics, which are in Celsius.
+// Metrics no rule applies to are not classified and nil is returned.
+func Classify(metrics map[string]float64, rules []ComfortRule) *Comfort {
+	var c *Comfort
+	for _, r := range rules {
+		if !r.applies(metrics) {
+			continue
+		}
+		if c == nil {
+			c = &Comfort{Level: ComfortOK}
+		}
+		if !r.matches(metrics) {
+			continue
+		}
+		switch {
+		case ComfortSeverity(r.Level) > ComfortSeverity(c.Level):
+			c.Level = r.Level
+			c.Reasons = []string{r.String()}
+		case r.Level == c.Level:
+			c.Reasons = append(c.Reasons, r.String())
+		}
+	}
+	return c
+}
@@ -0,0 +1,50 @@
+package data
+
+import "math"
+
+// Coefficients of the Magnus formula over water.
+const magnusA, magnusB = 17.62, 243.12
+
+// DewPoint returns the dew point in Celsius of air with temperature t in Celsius and relative
+// humidity rh in percent using the Magnus formula.
+func DewPoint(t, rh float64) float64 {
+	gamma := math.Log(rh/100) + magnusA*t/(magnusB+t)
+	return magnusB * gamma / (magnusA - gamma)
+}
+
+// saturationPressure returns the saturation vapor pressure in hPa at temperature t in Celsius.
+func saturationPressure(t float64) float64 {
+	return 6.112 * math.Exp(magnusA*t/(magnusB+t))
+}
+
+// AbsoluteHumidity returns the water vapor density in g/m³ of air with temperature t in Celsius
+// and relative humidity rh in percent.
+func AbsoluteHumidity(t, rh float64) float64 {
+	return 216.7 * rh / 100 * saturationPressure(t) / (273.15 + t)
+}
+
+// VPD returns the vapor pressure deficit in kPa of air with temperature t in Celsius and
+// relative humidity rh in percent.
+func VPD(t, rh float64) float64 {
+	return saturationPressure(t) / 10 * (1 - rh/100)
+}
+
+// HeatIndex returns the apparent temperature in Celsius of air with temperature t in Celsius and
+// relative humidity rh in percent as computed by the US National Weather Service.
+func HeatIndex(t, rh float64) float64 {
+	f := CelsiusToFahrenheit(t)
+	hi := 0.5 * (f + 61 + (f-68)*1.2 + rh*0.094)
+	if (hi+f)/2 < 80 {
+		return FahrenheitToCelsius(hi)
+	}
+
+	hi = -42.379 + 2.04901523*f + 10.14333127*rh - 0.22475541*f*rh - 0.00683783*f*f -
+		0.05481717*rh*rh + 0.00122874*f*f*rh + 0.00085282*f*rh*rh - 0.00000199*f*f*rh*rh
+	switch {
+	case rh < 13 && f >= 80 && f <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(f-95))/17)
+	case rh > 85 && f >= 80 && f <= 87:
+		hi += (rh - 85) / 10 * (87 - f) / 5
+	}
+	return FahrenheitToCelsius(hi)
+}
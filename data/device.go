@@ -0,0 +1,72 @@
+package data
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CanonicalID normalizes a device ID so the same device is always named the same way. IDs are
+// trimmed and lowercased, MACs such as AA:BB:CC:DD:EE:FF are written without separators, e.g.
+// aabbccddeeff, as Shelly devices embed them in their IDs.
+func CanonicalID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if mac, ok := parseMAC(id); ok {
+		return mac
+	}
+	return id
+}
+
+// IsMAC returns whether a canonical ID is a MAC.
+func IsMAC(id string) bool {
+	_, ok := parseMAC(id)
+	return ok && !strings.ContainsAny(id, ":-.")
+}
+
+// parseMAC parses a lowercase MAC with or without one kind of separator between the octets.
+func parseMAC(s string) (string, bool) {
+	var sep string
+	switch len(s) {
+	case 12:
+	case 17:
+		sep = s[2:3]
+		if sep != ":" && sep != "-" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	mac := strings.ReplaceAll(s, sep, "")
+	if len(mac) != 12 || (sep != "" && strings.Count(s, sep) != 5) {
+		return "", false
+	}
+	for _, c := range mac {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+	return mac, true
+}
+
+// WithDevice returns payload naming device instead of the device it was received from, i.e. the
+// src of websocket messages or the device of a ReportStatus. Payloads which already name device
+// or name no device at all are returned as is.
+func WithDevice(payload json.RawMessage, device string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(payload, &fields) != nil {
+		return payload
+	}
+	key := "device"
+	if _, ok := fields["method"]; ok {
+		key = "src"
+	}
+	var current string
+	if json.Unmarshal(fields[key], &current) != nil || current == device {
+		return payload
+	}
+	fields[key], _ = json.Marshal(device)
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return b
+}
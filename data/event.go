@@ -0,0 +1,54 @@
+package data
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is an event reported by a device in a NotifyEvent message, e.g. a button press,
+// temperature_change or scheduled_restart.
+type Event struct {
+	Device    string          `json:"device"`
+	Component string          `json:"component"`
+	Event     string          `json:"event"`
+	Time      time.Time       `json:"time"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Events returns the events contained in a NotifyEvent message. Events without timestamp get
+// the one of the message or, lacking that, received. Fields besides component, id, event and ts
+// (e.g. tC of temperature_change or data of a script event) are kept as data.
+func Events(payload []byte, received time.Time) []Event {
+	var msg struct {
+		Src    string `json:"src"`
+		Params struct {
+			TS     *float64                     `json:"ts"`
+			Events []map[string]json.RawMessage `json:"events"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil
+	}
+
+	if msg.Params.TS != nil {
+		received = time.UnixMilli(int64(*msg.Params.TS * 1000))
+	}
+	events := make([]Event, 0, len(msg.Params.Events))
+	for _, fields := range msg.Params.Events {
+		e := Event{Device: msg.Src, Time: received}
+		json.Unmarshal(fields["component"], &e.Component)
+		json.Unmarshal(fields["event"], &e.Event)
+		var ts float64
+		if json.Unmarshal(fields["ts"], &ts) == nil {
+			e.Time = time.UnixMilli(int64(ts * 1000))
+		}
+		for _, k := range []string{"component", "id", "event", "ts"} {
+			delete(fields, k)
+		}
+		if len(fields) > 0 {
+			e.Data, _ = json.Marshal(fields)
+		}
+		events = append(events, e)
+	}
+	return events
+}
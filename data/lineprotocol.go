@@ -0,0 +1,134 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point is a single line of the InfluxDB line protocol. Only numeric fields are kept.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]float64
+	Time        time.Time // zero if not set
+}
+
+// ParseLineProtocol parses InfluxDB line protocol. Timestamps are interpreted with the given
+// precision (ns, us, ms or s).
+func ParseLineProtocol(body []byte, precision string) ([]Point, error) {
+	var unit time.Duration
+	switch precision {
+	case "", "ns":
+		unit = time.Nanosecond
+	case "us":
+		unit = time.Microsecond
+	case "ms":
+		unit = time.Millisecond
+	case "s":
+		unit = time.Second
+	default:
+		return nil, fmt.Errorf("unsupported precision %q", precision)
+	}
+
+	var points []Point
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseLine(line, unit)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		points = append(points, p)
+	}
+	return points, scanner.Err()
+}
+
+func parseLine(line string, unit time.Duration) (Point, error) {
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return Point{}, fmt.Errorf("expected measurement, fields and optional timestamp")
+	}
+
+	p := Point{
+		Tags:   map[string]string{},
+		Fields: map[string]float64{},
+	}
+	key := splitUnescaped(sections[0], ',', false)
+	p.Measurement = unescape(key[0])
+	for _, tag := range key[1:] {
+		kv := splitUnescaped(tag, '=', false)
+		if len(kv) != 2 {
+			return Point{}, fmt.Errorf("invalid tag %q", tag)
+		}
+		p.Tags[unescape(kv[0])] = unescape(kv[1])
+	}
+
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		kv := splitUnescaped(field, '=', true)
+		if len(kv) != 2 {
+			return Point{}, fmt.Errorf("invalid field %q", field)
+		}
+		name, raw := unescape(kv[0]), kv[1]
+		switch {
+		case strings.HasPrefix(raw, `"`), raw == "t", raw == "T", raw == "true", raw == "True", raw == "TRUE",
+			raw == "f", raw == "F", raw == "false", raw == "False", raw == "FALSE":
+			// Strings and booleans are not measurements.
+			continue
+		case strings.HasSuffix(raw, "i"), strings.HasSuffix(raw, "u"):
+			raw = raw[:len(raw)-1]
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid value of field %q: %s", name, err)
+		}
+		p.Fields[name] = v
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		if ts > math.MaxInt64/int64(unit) || ts < math.MinInt64/int64(unit) {
+			return Point{}, fmt.Errorf("timestamp %q out of range", sections[2])
+		}
+		p.Time = time.Unix(0, ts*int64(unit))
+	}
+	return p, nil
+}
+
+// splitUnescaped splits s at every sep which is neither escaped with a backslash nor, if quotes
+// is set, within double quotes.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var (
+		parts    []string
+		start    int
+		inQuotes bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var lineProtocolUnescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\"`, `"`, `\\`, `\`)
+
+func unescape(s string) string {
+	return lineProtocolUnescaper.Replace(s)
+}
@@ -0,0 +1,32 @@
+package data
+
+import "regexp"
+
+const (
+	MetricTemperature = "temperature"
+	MetricHumidity    = "humidity"
+	MetricBattery     = "battery"
+	MetricDewPoint    = "dew_point"
+	MetricHeatIndex   = "heat_index"
+
+	MetricAbsoluteHumidity = "absolute_humidity"
+	MetricVPD              = "vpd"
+
+	// Metrics of other sensor types which are recorded as additional metrics.
+	MetricPressure    = "pressure"
+	MetricIlluminance = "illuminance"
+	MetricCO2         = "co2"
+)
+
+var metricPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidMetric returns whether name can be used as metric name by all sinks.
+func ValidMetric(name string) bool {
+	return metricPattern.MatchString(name)
+}
+
+// ExtractMetrics returns the numeric measurements contained in a cached payload which is either
+// a ReportStatus or a raw websocket message as sent by the device.
+func ExtractMetrics(payload []byte) map[string]float64 {
+	return ParseReading(payload).Metrics()
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a JSONPath-like expression selecting a single value of a JSON document, e.g.
+// $.params.sensor[0].temperature or $['temperature:0'].tC. The leading $ is optional.
+type Path []string
+
+// ParsePath parses a path expression. Keys containing dots or brackets have to be quoted in
+// brackets.
+func ParsePath(expr string) (Path, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	var p Path
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in path %q", expr)
+			}
+			p, s = append(p, s[:end]), s[end:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket in path %q", expr)
+			}
+			key := s[1:end]
+			if len(key) >= 2 && (key[0] == '\'' || key[0] == '"') && key[len(key)-1] == key[0] {
+				key = key[1 : len(key)-1]
+			} else if _, err := strconv.Atoi(key); err != nil {
+				return nil, fmt.Errorf("invalid index %q in path %q", key, expr)
+			}
+			p, s = append(p, key), s[end+1:]
+		default:
+			if len(p) > 0 {
+				return nil, fmt.Errorf("unexpected %q in path %q", s[0], expr)
+			}
+			s = "." + s // plain key without leading dot, e.g. "device"
+		}
+	}
+	return p, nil
+}
+
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, k := range p {
+		fmt.Fprintf(&b, "[%q]", k)
+	}
+	return b.String()
+}
+
+// Lookup returns the value at the path of a document decoded with json.Decoder.UseNumber or
+// false if the document has no such value.
+func (p Path) Lookup(doc any) (any, bool) {
+	v := doc
+	for _, k := range p {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[k]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Float returns the number at the path. Numbers encoded as strings are accepted as well.
+func (p Path) Float(doc any) (float64, bool) {
+	v, ok := p.Lookup(doc)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// StringValue returns the string at the path. Numbers are formatted as they appear in the document.
+func (p Path) StringValue(doc any) (string, bool) {
+	v, ok := p.Lookup(doc)
+	if !ok {
+		return "", false
+	}
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case json.Number:
+		return s.String(), true
+	}
+	return "", false
+}
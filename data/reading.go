@@ -0,0 +1,168 @@
+package data
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Reading is the normalized form of a cached payload, independent of whether the device sent a
+// websocket message or reported its values. Temperature and humidity are those of the first
+// channel, all channels are kept for devices with multiple probes.
+type Reading struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+	Battery     *float64 `json:"battery,omitempty"`
+	// ExternalPower is set if the device reports whether it is powered externally.
+	ExternalPower *bool    `json:"external_power,omitempty"`
+	RSSI          *float64 `json:"rssi,omitempty"`
+	// DeviceTime is the clock of the device when it sent the reading, if it reports one.
+	DeviceTime *time.Time `json:"device_time,omitempty"`
+	Channels   []Channel  `json:"channels,omitempty"`
+	// DewPoint and HeatIndex are computed from temperature and humidity if both are present.
+	DewPoint  *float64 `json:"dew_point,omitempty"`
+	HeatIndex *float64 `json:"heat_index,omitempty"`
+	// AbsoluteHumidity in g/m³ and VPD, the vapor pressure deficit in kPa, are computed as well.
+	AbsoluteHumidity *float64 `json:"absolute_humidity,omitempty"`
+	VPD              *float64 `json:"vpd,omitempty"`
+	// Values holds the metrics of other sensor types such as pressure or CO2.
+	Values map[string]float64 `json:"values,omitempty"`
+	// MAC is the hardware address of devices which report it.
+	MAC string `json:"mac,omitempty"`
+	// Model and Firmware identify the hardware and firmware of devices which report them.
+	// UpdateAvailable is set if the device reports whether a stable firmware update is offered,
+	// AvailableFirmware is its version.
+	Model             string `json:"model,omitempty"`
+	Firmware          string `json:"firmware,omitempty"`
+	UpdateAvailable   *bool  `json:"update_available,omitempty"`
+	AvailableFirmware string `json:"available_firmware,omitempty"`
+}
+
+// ParseReading normalizes a payload which is either a ReportStatus or a raw websocket message as
+// sent by the device. Values which can't be parsed are left empty.
+func ParseReading(payload []byte) Reading {
+	reading := parseReading(payload)
+	if reading.Temperature != nil && reading.Humidity != nil && *reading.Humidity > 0 {
+		t, rh := *reading.Temperature, *reading.Humidity
+		reading.DewPoint = derived(DewPoint(t, rh))
+		reading.HeatIndex = derived(HeatIndex(t, rh))
+		reading.AbsoluteHumidity = derived(AbsoluteHumidity(t, rh))
+		reading.VPD = derived(VPD(t, rh))
+	}
+	return reading
+}
+
+// derived rounds a computed value as sensors are far less accurate than its digits suggest.
+func derived(v float64) *float64 {
+	v = math.Round(v*100) / 100
+	return &v
+}
+
+func parseReading(payload []byte) Reading {
+	var reading Reading
+
+	var msg WSMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return reading
+	}
+	if msg.Method != "" {
+		if msg.Params == nil {
+			return reading
+		}
+		var raw struct {
+			Params map[string]json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(payload, &raw); err == nil {
+			reading.Channels = ParseChannels(raw.Params)
+			reading.Values = ParseComponents(raw.Params)
+		}
+		if len(reading.Channels) > 0 {
+			reading.Temperature = reading.Channels[0].Temperature
+			reading.Humidity = reading.Channels[0].Humidity
+		}
+		if p := msg.Params.DevicePower; p != nil {
+			if p.Battery != nil {
+				reading.Battery = p.Battery.Percent
+			}
+			if p.External != nil {
+				reading.ExternalPower = &p.External.Present
+			}
+		}
+		if w := msg.Params.Wifi; w != nil {
+			reading.RSSI = w.RSSI
+		}
+		ts := msg.Params.TS
+		if s := msg.Params.Sys; s != nil {
+			if s.UnixTime != nil {
+				ts = s.UnixTime
+			}
+			reading.MAC = s.MAC
+			if s.Device != nil {
+				reading.Model = s.Device.Model
+				reading.Firmware = s.Device.Firmware()
+			}
+			if s.AvailableUpdates != nil {
+				stable, ok := s.AvailableUpdates["stable"]
+				reading.UpdateAvailable = &ok
+				reading.AvailableFirmware = stable.Version
+			}
+		}
+		if ts != nil {
+			t := time.UnixMilli(int64(*ts * 1000))
+			reading.DeviceTime = &t
+		}
+		return reading
+	}
+
+	var r ReportStatus
+	if err := json.Unmarshal(payload, &r); err != nil {
+		return reading
+	}
+	reading.Temperature = r.Temperature
+	reading.Humidity = r.Humidity
+	reading.Battery = r.Battery
+	reading.Values = r.Metrics
+	return reading
+}
+
+// Metrics returns the values of the reading keyed by metric name. Values of channels other than
+// the first are named by ChannelMetric.
+func (r Reading) Metrics() map[string]float64 {
+	metrics := map[string]float64{}
+	for name, v := range r.Values {
+		metrics[name] = v
+	}
+	if r.Temperature != nil {
+		metrics[MetricTemperature] = *r.Temperature
+	}
+	if r.Humidity != nil {
+		metrics[MetricHumidity] = *r.Humidity
+	}
+	if r.Battery != nil {
+		metrics[MetricBattery] = *r.Battery
+	}
+	if r.DewPoint != nil {
+		metrics[MetricDewPoint] = *r.DewPoint
+	}
+	if r.HeatIndex != nil {
+		metrics[MetricHeatIndex] = *r.HeatIndex
+	}
+	if r.AbsoluteHumidity != nil {
+		metrics[MetricAbsoluteHumidity] = *r.AbsoluteHumidity
+	}
+	if r.VPD != nil {
+		metrics[MetricVPD] = *r.VPD
+	}
+	for i, c := range r.Channels {
+		if i == 0 {
+			continue
+		}
+		if c.Temperature != nil {
+			metrics[ChannelMetric(MetricTemperature, c.ID)] = *c.Temperature
+		}
+		if c.Humidity != nil {
+			metrics[ChannelMetric(MetricHumidity, c.ID)] = *c.Humidity
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// TimeSeries is a time series of a Prometheus remote_write request.
+type TimeSeries struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64 // Unix milliseconds
+}
+
+var errInvalidWriteRequest = errors.New("invalid remote_write request")
+
+// DecodeWriteRequest decodes an uncompressed prometheus.WriteRequest protobuf message. Metadata
+// and exemplars are skipped.
+func DecodeWriteRequest(b []byte) ([]TimeSeries, error) {
+	var series []TimeSeries
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		ts := TimeSeries{Labels: map[string]string{}}
+		if err := decodeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+			if typ != protowire.BytesType {
+				return nil
+			}
+			switch num {
+			case 1:
+				var name, value string
+				if err := decodeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+					switch num {
+					case 1:
+						name = string(v)
+					case 2:
+						value = string(v)
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				ts.Labels[name] = value
+			case 2:
+				var s Sample
+				if err := decodeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+					switch {
+					case num == 1 && typ == protowire.Fixed64Type:
+						bits, _ := protowire.ConsumeFixed64(v)
+						s.Value = math.Float64frombits(bits)
+					case num == 2 && typ == protowire.VarintType:
+						ts, _ := protowire.ConsumeVarint(v)
+						s.Timestamp = int64(ts)
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				ts.Samples = append(ts.Samples, s)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		series = append(series, ts)
+		return nil
+	})
+	return series, err
+}
+
+// decodeFields calls fn with the number, type and raw value of every field of a message.
+// Values of varint and fixed fields are passed encoded.
+func decodeFields(b []byte, fn func(protowire.Number, protowire.Type, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errInvalidWriteRequest
+		}
+		b = b[n:]
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			var m int
+			v, m = protowire.ConsumeBytes(b)
+			n = m
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return errInvalidWriteRequest
+		}
+		b = b[n:]
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
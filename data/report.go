@@ -1,7 +1,129 @@
 package data
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// UnitCelsius is the unit of all temperatures held by ReportStatus.
+const UnitCelsius = "C"
+
 type ReportStatus struct {
-	Device      string `json:"device"`
-	Temperature string `json:"temperature"`
-	Humidity    string `json:"humidity"`
+	Device      string   `json:"device"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+	Battery     *float64 `json:"battery,omitempty"`
+	// Metrics holds the values of other sensor types such as pressure or CO2 by metric name.
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	// Unit is the unit of Temperature. It is set when encoding a status with a temperature.
+	Unit string `json:"unit,omitempty"`
+}
+
+// Set sets the value of metric. Metrics other than temperature, humidity and battery are kept
+// in Metrics.
+func (r *ReportStatus) Set(metric string, v float64) {
+	switch metric {
+	case MetricTemperature:
+		r.Temperature = &v
+	case MetricHumidity:
+		r.Humidity = &v
+	case MetricBattery:
+		r.Battery = &v
+	default:
+		if r.Metrics == nil {
+			r.Metrics = map[string]float64{}
+		}
+		r.Metrics[metric] = v
+	}
+}
+
+// Validate returns an error if a value is outside of what sensors can plausibly measure.
+func (r ReportStatus) Validate() error {
+	for _, v := range []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{MetricTemperature, r.Temperature, -100, 150},
+		{MetricHumidity, r.Humidity, 0, 100},
+		{MetricBattery, r.Battery, 0, 100},
+	} {
+		if v.value == nil {
+			continue
+		}
+		if math.IsNaN(*v.value) || *v.value < v.min || *v.value > v.max {
+			return fmt.Errorf("%s %g out of range [%g, %g]", v.name, *v.value, v.min, v.max)
+		}
+	}
+	for name, v := range r.Metrics {
+		if !ValidMetric(name) {
+			return fmt.Errorf("invalid metric name %q", name)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%s %g is not a number", name, v)
+		}
+	}
+	return nil
+}
+
+func (r ReportStatus) MarshalJSON() ([]byte, error) {
+	type status ReportStatus
+	if r.Temperature != nil && r.Unit == "" {
+		r.Unit = UnitCelsius
+	}
+	return json.Marshal(status(r))
+}
+
+// UnmarshalJSON also accepts values encoded as strings as older records hold them.
+func (r *ReportStatus) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Device      string             `json:"device"`
+		Temperature json.RawMessage    `json:"temperature"`
+		Humidity    json.RawMessage    `json:"humidity"`
+		Battery     json.RawMessage    `json:"battery"`
+		Metrics     map[string]float64 `json:"metrics"`
+		Unit        string             `json:"unit"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*r = ReportStatus{Device: raw.Device, Metrics: raw.Metrics, Unit: raw.Unit}
+	for _, v := range []struct {
+		raw   json.RawMessage
+		value **float64
+	}{
+		{raw.Temperature, &r.Temperature},
+		{raw.Humidity, &r.Humidity},
+		{raw.Battery, &r.Battery},
+	} {
+		var err error
+		if *v.value, err = parseValue(v.raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseValue(raw json.RawMessage) (*float64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if s == "" {
+			return nil, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", s)
+		}
+		return &v, nil
+	}
+	var v float64
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
 }
@@ -0,0 +1,86 @@
+package data
+
+import "strings"
+
+// Params is the typed status of a device as sent in the params of NotifyFullStatus and
+// NotifyStatus messages. Components missing in a message are nil.
+type Params struct {
+	// TS is the unix time of the message in seconds as reported by the device.
+	TS          *float64           `json:"ts,omitempty"`
+	Temperature *TemperatureStatus `json:"temperature:0,omitempty"`
+	Humidity    *HumidityStatus    `json:"humidity:0,omitempty"`
+	DevicePower *DevicePowerStatus `json:"devicepower:0,omitempty"`
+	Wifi        *WifiStatus        `json:"wifi,omitempty"`
+	Sys         *SysStatus         `json:"sys,omitempty"`
+}
+
+type TemperatureStatus struct {
+	ID     int      `json:"id"`
+	TC     *float64 `json:"tC"`
+	TF     *float64 `json:"tF"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type HumidityStatus struct {
+	ID     int      `json:"id"`
+	RH     *float64 `json:"rh"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type DevicePowerStatus struct {
+	ID      int `json:"id"`
+	Battery *struct {
+		V       *float64 `json:"V"`
+		Percent *float64 `json:"percent"`
+	} `json:"battery,omitempty"`
+	External *struct {
+		Present bool `json:"present"`
+	} `json:"external,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type WifiStatus struct {
+	StaIP  string   `json:"sta_ip,omitempty"`
+	Status string   `json:"status,omitempty"`
+	SSID   string   `json:"ssid,omitempty"`
+	RSSI   *float64 `json:"rssi,omitempty"`
+}
+
+type SysStatus struct {
+	// Device identifies the hardware and firmware of the device, if it includes it.
+	Device          *SysDevice `json:"device,omitempty"`
+	MAC             string     `json:"mac,omitempty"`
+	RestartRequired bool       `json:"restart_required"`
+	Time            string     `json:"time,omitempty"`
+	UnixTime        *float64   `json:"unixtime,omitempty"`
+	Uptime          *float64   `json:"uptime,omitempty"`
+	RAMSize         *float64   `json:"ram_size,omitempty"`
+	RAMFree         *float64   `json:"ram_free,omitempty"`
+	FSSize          *float64   `json:"fs_size,omitempty"`
+	FSFree          *float64   `json:"fs_free,omitempty"`
+	// AvailableUpdates holds the firmware versions offered by the device by channel, e.g. stable
+	// or beta. It is empty if the firmware is up to date.
+	AvailableUpdates map[string]struct {
+		Version string `json:"version"`
+	} `json:"available_updates,omitempty"`
+}
+
+type SysDevice struct {
+	Name  string `json:"name,omitempty"`
+	MAC   string `json:"mac,omitempty"`
+	Model string `json:"model,omitempty"`
+	FWID  string `json:"fw_id,omitempty"`
+	Ver   string `json:"ver,omitempty"`
+}
+
+// Firmware returns the firmware version of the device, falling back to the version part of the
+// firmware ID, e.g. 1.4.4-g6d2a586 of 20241011-114455/1.4.4-g6d2a586.
+func (d SysDevice) Firmware() string {
+	if d.Ver != "" {
+		return d.Ver
+	}
+	if _, ver, ok := strings.Cut(d.FWID, "/"); ok {
+		return ver
+	}
+	return d.FWID
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"strconv"
+	"strings"
+)
+
+// UnitFahrenheit is the unit temperatures can be converted to on output.
+const UnitFahrenheit = "F"
+
+func CelsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func FahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// convertTemperature converts a temperature in Celsius to unit.
+func convertTemperature(c *float64, unit string) *float64 {
+	if c == nil || unit != UnitFahrenheit {
+		return c
+	}
+	f := CelsiusToFahrenheit(*c)
+	return &f
+}
+
+// MetricDiffSuffix names metrics holding the difference of a metric between two devices.
+const MetricDiffSuffix = "_diff"
+
+// ConvertMetric converts the value of a metric to unit if the metric is a temperature in
+// Celsius, i.e. a temperature of any channel, the dew point or the heat index. Differences of
+// temperatures are only scaled.
+func ConvertMetric(metric string, v float64, unit string) float64 {
+	if unit != UnitFahrenheit {
+		return v
+	}
+	if base, ok := strings.CutSuffix(metric, MetricDiffSuffix); ok {
+		if isTemperature(base) {
+			return v * 9 / 5
+		}
+		return v
+	}
+	if isTemperature(metric) {
+		return CelsiusToFahrenheit(v)
+	}
+	return v
+}
+
+func isTemperature(metric string) bool {
+	switch metric {
+	case MetricTemperature, MetricDewPoint, MetricHeatIndex:
+		return true
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(metric, MetricTemperature+"_"))
+	return strings.HasPrefix(metric, MetricTemperature+"_") && err == nil
+}
+
+// InUnit returns the status with its temperature converted to unit.
+func (r ReportStatus) InUnit(unit string) ReportStatus {
+	if r.Temperature == nil || unit != UnitFahrenheit || r.Unit == UnitFahrenheit {
+		return r
+	}
+	r.Temperature = convertTemperature(r.Temperature, unit)
+	r.Unit = unit
+	return r
+}
+
+// InUnit returns the reading with all temperatures converted to unit.
+func (r Reading) InUnit(unit string) Reading {
+	if unit == UnitCelsius {
+		return r
+	}
+	r.Temperature = convertTemperature(r.Temperature, unit)
+	r.DewPoint = convertTemperature(r.DewPoint, unit)
+	r.HeatIndex = convertTemperature(r.HeatIndex, unit)
+	channels := make([]Channel, len(r.Channels))
+	for i, c := range r.Channels {
+		c.Temperature = convertTemperature(c.Temperature, unit)
+		channels[i] = c
+	}
+	if r.Channels != nil {
+		r.Channels = channels
+	}
+	return r
+}
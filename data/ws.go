@@ -1,5 +1,10 @@
 package data
 
+import (
+	"encoding/json"
+	"time"
+)
+
 const (
 	MethodNotifyFullStatus = "NotifyFullStatus"
 	MethodNotifyStatus     = "NotifyStatus"
@@ -7,7 +12,46 @@ const (
 )
 
 type WSMessage struct {
-	Src    string `json:"src"`    // "src":"shellyplusht-..."
-	Dst    string `json:"dst"`    // "dst":"ws"
-	Method string `json:"method"` // "method":"NotifyFullStatus"
+	Src    string  `json:"src"`    // "src":"shellyplusht-..."
+	Dst    string  `json:"dst"`    // "dst":"ws"
+	Method string  `json:"method"` // "method":"NotifyFullStatus"
+	Params *Params `json:"params,omitempty"`
+}
+
+// DeviceTime returns the time reported by the device in params.ts of a websocket message.
+func DeviceTime(payload []byte) (time.Time, bool) {
+	var msg WSMessage
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Params == nil || msg.Params.TS == nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(*msg.Params.TS * 1000)), true
+}
+
+// MergeStatus applies the params of a NotifyStatus message onto a full status message and
+// returns the result as NotifyFullStatus. Components present in both are merged recursively so
+// a delta only carrying e.g. the humidity keeps all other values.
+func MergeStatus(full, delta []byte) ([]byte, error) {
+	var base, update map[string]any
+	if err := json.Unmarshal(full, &base); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(delta, &update); err != nil {
+		return nil, err
+	}
+	merged := mergeObjects(base, update)
+	merged["method"] = MethodNotifyFullStatus
+	return json.Marshal(merged)
+}
+
+func mergeObjects(dst, src map[string]any) map[string]any {
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			if prev, ok := dst[k].(map[string]any); ok {
+				dst[k] = mergeObjects(prev, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
 }
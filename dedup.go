@@ -0,0 +1,58 @@
+package main
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+)
+
+// deduper detects readings which repeat the last stored reading of a device.
+type deduper struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	last map[string]store.Record
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{
+		window: window,
+		last:   map[string]store.Record{},
+	}
+}
+
+// duplicate reports whether r has the same values and device timestamp as the last stored
+// reading of its device and was received within the dedup window. Otherwise, r is remembered
+// as the last stored reading.
+func (d *deduper) duplicate(r store.Record) bool {
+	if d.window <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev, ok := d.last[r.Device]
+	if ok && r.Received.Sub(prev.Received) < d.window && sameReading(prev, r) {
+		return true
+	}
+	d.last[r.Device] = r
+	return false
+}
+
+func (d *deduper) delete(device string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.last, device)
+}
+
+func sameReading(a, b store.Record) bool {
+	ta, oka := data.DeviceTime(a.Payload)
+	tb, okb := data.DeviceTime(b.Payload)
+	if oka != okb || !ta.Equal(tb) {
+		return false
+	}
+	return maps.Equal(a.Reading.Metrics(), b.Reading.Metrics())
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/finfinack/measure/data"
+)
+
+var deviceAliases stringList
+
+func init() {
+	flag.Var(&deviceAliases, "deviceAlias", "Map a device ID, usually a MAC sent by devices reporting without their name, onto the ID the device is recorded as, e.g. A4:CF:12:F4:56:78=shellyht-f45678. Can be repeated.")
+}
+
+// deviceIDs canonicalizes the IDs devices are received with so the same physical sensor never
+// appears twice. Besides the configured aliases, it learns the MACs of devices reporting them.
+type deviceIDs struct {
+	mu      sync.RWMutex
+	aliases map[string]string // canonical alias -> device
+}
+
+// newDeviceIDs parses the -deviceAlias flags.
+func newDeviceIDs(values []string) (*deviceIDs, error) {
+	d := &deviceIDs{aliases: map[string]string{}}
+	for _, v := range values {
+		alias, device, ok := strings.Cut(v, "=")
+		alias, device = data.CanonicalID(alias), data.CanonicalID(device)
+		if !ok || alias == "" || device == "" {
+			return nil, fmt.Errorf("invalid alias %q, expected <alias>=<device>", v)
+		}
+		d.aliases[alias] = device
+	}
+	return d, nil
+}
+
+// canonical returns the ID id is recorded as.
+func (d *deviceIDs) canonical(id string) string {
+	id = data.CanonicalID(id)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if device, ok := d.aliases[id]; ok {
+		return device
+	}
+	return id
+}
+
+// learn maps the MAC a device reported onto its ID, unless the MAC is mapped already. It returns
+// whether the MAC was new.
+func (d *deviceIDs) learn(mac, device string) bool {
+	mac = data.CanonicalID(mac)
+	if !data.IsMAC(mac) || mac == device {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.aliases[mac]; ok {
+		return false
+	}
+	d.aliases[mac] = device
+	return true
+}
+
+// alias maps another ID onto device. It fails if the alias is mapped onto a different device.
+func (d *deviceIDs) alias(alias, device string) error {
+	alias = data.CanonicalID(alias)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if other, ok := d.aliases[alias]; ok && other != device {
+		return fmt.Errorf("alias %q is in use by %q", alias, other)
+	}
+	d.aliases[alias] = device
+	return nil
+}
+
+// forget removes an alias.
+func (d *deviceIDs) forget(alias string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.aliases, data.CanonicalID(alias))
+}
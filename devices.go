@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+var devicesFile = flag.String("devicesFile", "", "Path to the JSON file metadata of devices such as friendly names is read from and written to, e.g. {\"shellyplusht-abc\": {\"name\": \"Bathroom\"}}. Empty keeps changes made via the API in memory only.")
+
+// deviceMeta is what users attached to a device.
+type deviceMeta struct {
+	Name string `json:"name,omitempty"`
+	// Tags are arbitrary key/value pairs such as room=kitchen which can be used as filters.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Groups are the names of the groups, e.g. rooms, the device belongs to.
+	Groups []string `json:"groups,omitempty"`
+	// Registered is set for devices provisioned ahead of their reports. Aliases are other IDs
+	// the device reports with and Interval, a duration such as 15m, is how often it is expected
+	// to report.
+	Registered bool     `json:"registered,omitempty"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Interval   string   `json:"interval,omitempty"`
+	// TokenHash is the SHA-256 hash of the write token issued to the device, see issueTokenHandler.
+	TokenHash string `json:"token_hash,omitempty"`
+}
+
+func (d deviceMeta) empty() bool {
+	return d.Name == "" && len(d.Tags) == 0 && len(d.Groups) == 0 && !d.Registered && d.TokenHash == ""
+}
+
+// matches returns whether the device has all tags of filter.
+func (d deviceMeta) matches(filter map[string]string) bool {
+	for k, v := range filter {
+		if d.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTagFilter parses tag filters given as <key>:<value>.
+func parseTagFilter(values []string) (map[string]string, error) {
+	filter := map[string]string{}
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag filter %q, expected <key>:<value>", v)
+		}
+		filter[key] = value
+	}
+	return filter, nil
+}
+
+// registry holds the metadata of all devices and writes every change to its file if it has one.
+type registry struct {
+	mu      sync.Mutex
+	path    string
+	devices map[string]deviceMeta
+}
+
+func loadRegistry(path string) (*registry, error) {
+	r := &registry{path: path, devices: map[string]deviceMeta{}}
+	if path == "" {
+		return r, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var devices map[string]deviceMeta
+	if err := json.Unmarshal(b, &devices); err != nil {
+		return nil, err
+	}
+	for device, meta := range devices {
+		r.devices[data.CanonicalID(device)] = meta
+	}
+	return r, nil
+}
+
+// members returns the devices belonging to a group.
+func (r *registry) members(group string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []string
+	for device, meta := range r.devices {
+		if slices.Contains(meta.Groups, group) {
+			devices = append(devices, device)
+		}
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// registered returns the devices registered ahead of their reports.
+func (r *registry) registered() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []string
+	for device, meta := range r.devices {
+		if meta.Registered {
+			devices = append(devices, device)
+		}
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// tokenDevice returns the device the token with hash was issued to.
+func (r *registry) tokenDevice(hash string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for device, meta := range r.devices {
+		if meta.TokenHash != "" && meta.TokenHash == hash {
+			return device, true
+		}
+	}
+	return "", false
+}
+
+func (r *registry) get(device string) deviceMeta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.devices[device]
+}
+
+// update changes the metadata of a device and persists the result. Devices left without any
+// metadata are removed.
+func (r *registry) update(device string, fn func(*deviceMeta)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	meta := r.devices[device]
+	// Callers of get may still hold the previous tags.
+	meta.Tags = maps.Clone(meta.Tags)
+	meta.Groups = slices.Clone(meta.Groups)
+	meta.Aliases = slices.Clone(meta.Aliases)
+	fn(&meta)
+	if meta.empty() {
+		delete(r.devices, device)
+	} else {
+		r.devices[device] = meta
+	}
+	return r.save()
+}
+
+// remove removes all metadata of a device.
+func (r *registry) remove(device string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.devices[device]; !ok {
+		return nil
+	}
+	delete(r.devices, device)
+	return r.save()
+}
+
+// save atomically replaces the file of the registry. The caller must hold the lock.
+func (r *registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(r.devices, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(r.path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// displayName returns the friendly name of a device or its ID if it has none.
+func (m *MeasureServer) displayName(device string) string {
+	if name := m.registry.get(device).Name; name != "" {
+		return name
+	}
+	return device
+}
+
+// nameHandler assigns a friendly name to a device, e.g. {"name": "Bathroom"}.
+func (m *MeasureServer) nameHandler(ctx *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("name not set"))
+		return
+	}
+	device := m.ids.canonical(ctx.Param("id"))
+	if err := m.registry.update(device, func(d *deviceMeta) { d.Name = req.Name }); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+		"name":   req.Name,
+	})
+}
+
+// deleteNameHandler removes the friendly name of a device.
+func (m *MeasureServer) deleteNameHandler(ctx *gin.Context) {
+	if err := m.registry.update(m.ids.canonical(ctx.Param("id")), func(d *deviceMeta) { d.Name = "" }); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// patchDeviceHandler changes the metadata of a device. Tags set to null or an empty string are
+// removed, e.g. {"name": "Bathroom", "tags": {"room": "bathroom", "floor": null}}. Groups
+// replace the previous ones, an empty list removes the device from all groups.
+func (m *MeasureServer) patchDeviceHandler(ctx *gin.Context) {
+	var req struct {
+		Name   *string            `json:"name"`
+		Tags   map[string]*string `json:"tags"`
+		Groups *[]string          `json:"groups"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	for k := range req.Tags {
+		if k == "" || strings.Contains(k, ":") {
+			abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("invalid tag %q", k))
+			return
+		}
+	}
+
+	device := m.ids.canonical(ctx.Param("id"))
+	var meta deviceMeta
+	if err := m.registry.update(device, func(d *deviceMeta) {
+		if req.Name != nil {
+			d.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Groups != nil {
+			d.Groups = nil
+			for _, g := range *req.Groups {
+				if g = strings.TrimSpace(g); g != "" && !slices.Contains(d.Groups, g) {
+					d.Groups = append(d.Groups, g)
+				}
+			}
+		}
+		for k, v := range req.Tags {
+			if v == nil || *v == "" {
+				delete(d.Tags, k)
+				continue
+			}
+			if d.Tags == nil {
+				d.Tags = map[string]string{}
+			}
+			d.Tags[k] = *v
+		}
+		meta = *d
+	}); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+		"name":   meta.Name,
+		"tags":   meta.Tags,
+		"groups": meta.Groups,
+	})
+}
+
+// deleteDeviceHandler removes a device from the cache, the history including the persistent
+// store and its metadata, e.g. after a sensor was retired. Archived readings are kept.
+func (m *MeasureServer) deleteDeviceHandler(ctx *gin.Context) {
+	device := m.ids.canonical(ctx.Param("id"))
+	// Refuse to delete a device the store would restore on the next start.
+	d, ok := m.Store.(store.Deleter)
+	if m.Store != nil && !ok {
+		abortWithError(ctx, http.StatusNotImplemented, errors.New("store does not support deleting readings"))
+		return
+	}
+	if err := m.Cache.Delete(device); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if ok {
+		if err := d.DeleteRange(device, time.Time{}, time.Time{}); err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	aliases := m.registry.get(device).Aliases
+	if err := m.registry.remove(device); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	for _, alias := range aliases {
+		m.ids.forget(alias)
+	}
+	m.History.Delete(device)
+	m.seen.delete(device)
+	m.rolling.delete(device)
+	m.spikes.delete(device)
+	m.comfort.delete(device)
+	m.dedup.delete(device)
+	m.Logger.Infof("deleted device %q", device)
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+	})
+}
+
+// deviceSummary is the overview of a device returned by the devices endpoint.
+type deviceSummary struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Groups []string          `json:"groups,omitempty"`
+	Model  string            `json:"model,omitempty"`
+	// LastSeen is the last contact with the device, which may have no cached reading anymore.
+	LastSeen time.Time `json:"last_seen"`
+	Stale    bool      `json:"stale"`
+	// Registered devices are listed before they report and flagged as Silent if they don't.
+	Registered bool     `json:"registered,omitempty"`
+	Silent     bool     `json:"silent,omitempty"`
+	Battery    *float64 `json:"battery,omitempty"`
+	// Values holds the current value of every metric of the cached reading.
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+// devicesHandler lists all known devices sorted by ID with their metadata and current values,
+// optionally filtered by tags given as ?tag=<key>:<value>. Temperatures are returned in unit.
+func (m *MeasureServer) devicesHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Tags []string `form:"tag"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	tags, err := parseTagFilter(parsedQueryParameters.Tags)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	items, err := m.Cache.Items()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	now := time.Now()
+	summaries := map[string]*deviceSummary{}
+	summary := func(device string) *deviceSummary {
+		s, ok := summaries[device]
+		if !ok {
+			meta := m.registry.get(device)
+			s = &deviceSummary{ID: device, Name: meta.Name, Tags: meta.Tags, Groups: meta.Groups}
+			summaries[device] = s
+		}
+		return s
+	}
+	for device, r := range items {
+		s := summary(device)
+		s.Model = r.Reading.Model
+		s.Battery = r.Reading.Battery
+		s.Values = r.Reading.InUnit(unit).Metrics()
+		// Readings restored on startup predate the tracked contacts.
+		s.LastSeen = r.Received
+	}
+	for device, t := range m.seen.items() {
+		if s := summary(device); t.After(s.LastSeen) {
+			s.LastSeen = t
+		}
+	}
+	for _, device := range m.registry.registered() {
+		summary(device)
+	}
+
+	devices := []deviceSummary{}
+	for _, s := range summaries {
+		meta := m.registry.get(s.ID)
+		if !meta.matches(tags) {
+			continue
+		}
+		s.Stale = now.Sub(s.LastSeen) > *staleAfter
+		s.Registered = meta.Registered
+		s.Silent = silent(meta, s.LastSeen, now)
+		devices = append(devices, *s)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	ctx.JSON(http.StatusOK, gin.H{
+		"devices": devices,
+		"unit":    unit,
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+// diffHandler returns per device the metrics which changed since a given time, e.g. for wall
+// displays refreshing often. Metrics no longer reported are returned as null and devices without
+// changes left out. The state at since is taken from the in-memory history, devices whose reading
+// at that time is no longer held there are returned in full. The returned time is meant to be
+// passed as since of the next request.
+func (m *MeasureServer) diffHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Since time.Time `form:"since"`
+		Tags  []string  `form:"tag"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	since := parsedQueryParameters.Since
+	if since.IsZero() {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("since not set"))
+		return
+	}
+	tags, err := parseTagFilter(parsedQueryParameters.Tags)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now()
+	items, err := m.Cache.Items()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	changes := map[string]map[string]*float64{}
+	for device, r := range items {
+		if !r.Received.After(since) || !m.registry.get(device).matches(tags) {
+			continue
+		}
+		var before map[string]float64
+		if previous := m.History.Range(device, time.Time{}, since); len(previous) > 0 {
+			before = previous[len(previous)-1].Reading.Metrics()
+		}
+		changed := map[string]*float64{}
+		after := r.Reading.Metrics()
+		for metric, v := range after {
+			if old, ok := before[metric]; ok && old == v {
+				continue
+			}
+			v = math.Round(data.ConvertMetric(metric, v, unit)*100) / 100
+			changed[metric] = &v
+		}
+		for metric := range before {
+			if _, ok := after[metric]; !ok {
+				changed[metric] = nil
+			}
+		}
+		if len(changed) > 0 {
+			changes[device] = changed
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"devices": changes,
+		"since":   since,
+		"time":    now,
+		"unit":    unit,
+	})
+}
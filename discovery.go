@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grandcat/zeroconf"
+)
+
+const shellyService = "_shelly._tcp"
+
+var (
+	discoveryEnabled  = flag.Bool("discovery", false, "Discover Shelly devices on the local network via mDNS.")
+	discoveryInterval = flag.Duration("discoveryInterval", 5*time.Minute, "Interval in which the local network is browsed for Shelly devices.")
+	discoveryPoll     = flag.Bool("discoveryPoll", false, "Poll discovered devices via HTTP RPC, see -pollInterval.")
+)
+
+// discoveredDevice is a Shelly device announced via mDNS.
+type discoveredDevice struct {
+	ID       string    `json:"id"`
+	Host     string    `json:"host"`
+	Gen      string    `json:"gen,omitempty"`
+	App      string    `json:"app,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// discovery browses the local network for Shelly devices. Found devices are marked as seen and,
+// if a poller is set, polled.
+type discovery struct {
+	m        *MeasureServer
+	poller   *poller // optional
+	interval time.Duration
+
+	mu      sync.RWMutex
+	devices map[string]discoveredDevice
+}
+
+func newDiscovery(m *MeasureServer, p *poller, interval time.Duration) *discovery {
+	return &discovery{
+		m:        m,
+		poller:   p,
+		interval: interval,
+		devices:  map[string]discoveredDevice{},
+	}
+}
+
+func (d *discovery) run() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if err := d.browse(); err != nil {
+			d.m.Logger.Warnf("unable to browse for Shelly devices: %s", err)
+		}
+		<-ticker.C
+	}
+}
+
+// browse listens for announcements for a fraction of the interval as devices answer queries
+// within a few seconds.
+func (d *discovery) browse() error {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), min(d.interval/2, 10*time.Second))
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := make(chan struct{})
+	go func() {
+		defer close(found)
+		for e := range entries {
+			d.register(e)
+		}
+	}()
+	if err := resolver.Browse(ctx, shellyService, "local.", entries); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	<-found
+	return nil
+}
+
+func (d *discovery) register(e *zeroconf.ServiceEntry) {
+	var ip net.IP
+	switch {
+	case len(e.AddrIPv4) > 0:
+		ip = e.AddrIPv4[0]
+	case len(e.AddrIPv6) > 0:
+		ip = e.AddrIPv6[0]
+	default:
+		return
+	}
+	dev := discoveredDevice{
+		ID:       e.Instance,
+		Host:     net.JoinHostPort(ip.String(), strconv.Itoa(e.Port)),
+		LastSeen: time.Now(),
+	}
+	for _, txt := range e.Text {
+		key, value, _ := strings.Cut(txt, "=")
+		switch key {
+		case "gen":
+			dev.Gen = value
+		case "app":
+			dev.App = value
+		case "ver":
+			dev.Version = value
+		}
+	}
+
+	d.mu.Lock()
+	prev, known := d.devices[dev.ID]
+	d.devices[dev.ID] = dev
+	d.mu.Unlock()
+	if !known {
+		d.m.Logger.Infof("discovered %s (%s) at %s", dev.ID, dev.App, dev.Host)
+	}
+	// Announcements count as contact so discovered devices are listed before they report.
+	d.m.seen.touch(d.m.ids.canonical(dev.ID), dev.LastSeen)
+
+	// Only Gen2+ devices speak RPC, Gen1 devices announce no generation.
+	if d.poller == nil || dev.Gen == "" || dev.Gen == "1" {
+		return
+	}
+	if known && prev.Host != dev.Host {
+		d.poller.remove(prev.Host)
+	}
+	d.poller.add(dev.Host)
+}
+
+func (d *discovery) list() []discoveredDevice {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	devices := make([]discoveredDevice, 0, len(d.devices))
+	for _, dev := range d.devices {
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices
+}
+
+func (d *discovery) handler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"devices": d.list(),
+	})
+}
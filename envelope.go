@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion is the version of the API served below apiV2Endpoint.
+const apiVersion = 2
+
+// envelopeMeta lists the keys of v1 responses which are moved into the meta of the envelope.
+var envelopeMeta = []string{"unit", "total", "next", "since"}
+
+// envelopeResponse is the structure shared by all JSON responses of the v2 API. Exactly one of
+// Data and Error is set.
+type envelopeResponse struct {
+	Data  json.RawMessage `json:"data"`
+	Error *apiError       `json:"error"`
+	Meta  map[string]any  `json:"meta"`
+}
+
+// envelopeWriter holds back the body written by a handler so that it can be wrapped.
+type envelopeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// WriteHeaderNow is deferred until the body is complete as errors change the status and type.
+func (w *envelopeWriter) WriteHeaderNow() {}
+
+// envelope wraps the responses of the v1 handlers in an envelopeResponse: JSON bodies become the
+// data, errors raised via abortWithError the error. Other content such as CSV is passed through.
+func envelope(ctx *gin.Context) {
+	w := &envelopeWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = w
+	ctx.Next()
+	ctx.Writer = w.ResponseWriter
+
+	resp := envelopeResponse{
+		Meta: map[string]any{
+			"version": apiVersion,
+			"time":    time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	status := ctx.Writer.Status()
+	if err := ctx.Errors.Last(); err != nil {
+		if status < http.StatusBadRequest {
+			status = http.StatusInternalServerError
+		}
+		ctx.Header("Content-Type", "")
+		ctx.Header("Content-Disposition", "")
+		e := newAPIError(ctx, status, err)
+		resp.Error = &e
+		ctx.JSON(status, resp)
+		return
+	}
+	if !strings.HasPrefix(ctx.Writer.Header().Get("Content-Type"), gin.MIMEJSON) {
+		ctx.Writer.WriteHeaderNow()
+		ctx.Writer.Write(w.body.Bytes())
+		return
+	}
+
+	resp.Data = w.body.Bytes()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Data, &fields); err == nil {
+		for _, key := range envelopeMeta {
+			if v, ok := fields[key]; ok {
+				resp.Meta[key] = v
+				delete(fields, key)
+			}
+		}
+		if data, err := json.Marshal(fields); err == nil {
+			resp.Data = data
+		}
+	}
+	ctx.JSON(status, resp)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+	// maxRequestIDLength limits request IDs passed in by proxies.
+	maxRequestIDLength = 128
+)
+
+// apiError describes why a request failed.
+type apiError struct {
+	Status int `json:"status"`
+	// Code is the snake cased status text, e.g. not_found.
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// newAPIError returns the error of a failed request. Details of server errors are only logged.
+func newAPIError(ctx *gin.Context, status int, err error) apiError {
+	e := apiError{
+		Status:    status,
+		Code:      strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"),
+		Message:   err.Error(),
+		RequestID: ctx.GetString(requestIDKey),
+	}
+	if status >= http.StatusInternalServerError {
+		e.Message = http.StatusText(status)
+	}
+	return e
+}
+
+// abortWithError aborts a request with err rendered as JSON, e.g.
+// {"error": {"status": 400, "code": "bad_request", "message": "device not set", "request_id": "..."}}.
+// The error is attached to the context so that it is logged as well.
+func abortWithError(ctx *gin.Context, status int, err error) {
+	ctx.Error(err)
+	ctx.AbortWithStatusJSON(status, gin.H{
+		"error": newAPIError(ctx, status, err),
+	})
+}
+
+// requestID assigns every request an ID which is returned in the X-Request-ID header and error
+// responses. IDs set by proxies in front are kept.
+func requestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	ctx.Set(requestIDKey, id)
+	ctx.Header(requestIDHeader, id)
+	ctx.Next()
+}
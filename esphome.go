@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var esphomeNodes stringList
+
+func init() {
+	flag.Var(&esphomeNodes, "esphomeNode", "MQTT topic prefix (usually the node name) of an ESPHome node whose sensors are recorded. Can be repeated.")
+}
+
+// esphomeMetric derives the metric from the object ID of an ESPHome sensor as the state topic
+// carries no device class.
+func esphomeMetric(objectID string) string {
+	switch {
+	case strings.Contains(objectID, "temp"):
+		return data.MetricTemperature
+	case strings.Contains(objectID, "hum"):
+		return data.MetricHumidity
+	case strings.Contains(objectID, "battery"):
+		return data.MetricBattery
+	case strings.Contains(objectID, "pressure"):
+		return data.MetricPressure
+	case strings.Contains(objectID, "illuminance"), strings.Contains(objectID, "lux"):
+		return data.MetricIlluminance
+	case strings.Contains(objectID, "co2"):
+		return data.MetricCO2
+	}
+	return ""
+}
+
+// esphomeHandler returns an MQTT handler for the state topics <node>/sensor/<object id>/state
+// of an ESPHome node. Every message carries the state of a single sensor, so it is merged into
+// the cached reading of the node. The native API is not supported.
+func (m *MeasureServer) esphomeHandler(node string) mqtt.MessageHandler {
+	return func(_ mqtt.Client, message mqtt.Message) {
+		parts := strings.Split(strings.TrimPrefix(message.Topic(), node+"/"), "/")
+		if len(parts) != 3 || parts[0] != "sensor" || parts[2] != "state" {
+			return
+		}
+		metric := esphomeMetric(parts[1])
+		if metric == "" {
+			return
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(string(message.Payload())), 64)
+		// Sensors without a state publish "nan", which parses as NaN.
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			m.Logger.Debugf("ignoring state %q on %q", message.Payload(), message.Topic())
+			return
+		}
+		mqttMessages.WithLabelValues("esphome").Inc()
+
+		ctx, span := tracer.Start(context.Background(), "esphome", trace.WithAttributes(
+			attribute.String("device", node),
+			attribute.String("topic", message.Topic()),
+		))
+		defer span.End()
+
+		r := data.ReportStatus{}
+		if prev, err := m.Cache.Get(m.ids.canonical(node)); err == nil {
+			json.Unmarshal(prev.Payload, &r)
+		}
+		r.Device = node
+		// The cached values are already calibrated, so only the new one is.
+		if c, ok := m.calibrations[node][metric]; ok {
+			v = c.Apply(v)
+		}
+		r.Set(metric, v)
+		if err := r.Validate(); err != nil {
+			m.Logger.Warnf("dropping invalid reading of %q: %s", node, err)
+			return
+		}
+		status, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		m.recordCalibrated(ctx, node, json.RawMessage(status), time.Now())
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/sink"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	eventsDepth  = flag.Int("eventsDepth", 1000, "Number of device events to keep in memory for the events endpoint.")
+	eventWebhook = flag.String("eventWebhook", "", "URL device events are POSTed to. Empty disables the event webhook.")
+)
+
+// eventLog keeps the most recent device events in memory.
+type eventLog struct {
+	depth int
+	hook  *sink.EventHook // optional
+
+	mu     sync.RWMutex
+	events []data.Event
+}
+
+func newEventLog(depth int, hook *sink.EventHook) *eventLog {
+	return &eventLog{
+		depth: depth,
+		hook:  hook,
+	}
+}
+
+func (l *eventLog) add(e data.Event) error {
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	if len(l.events) > l.depth {
+		l.events = l.events[len(l.events)-l.depth:]
+	}
+	l.mu.Unlock()
+	if l.hook != nil {
+		return l.hook.Send(e)
+	}
+	return nil
+}
+
+// eventFilter selects events. Empty fields match every event.
+type eventFilter struct {
+	device string
+	event  string
+	from   time.Time
+	to     time.Time
+}
+
+func (f eventFilter) matches(e data.Event) bool {
+	switch {
+	case f.device != "" && e.Device != f.device:
+		return false
+	case f.event != "" && e.Event != f.event:
+		return false
+	case !f.from.IsZero() && e.Time.Before(f.from):
+		return false
+	case !f.to.IsZero() && e.Time.After(f.to):
+		return false
+	}
+	return true
+}
+
+// list returns the events matching filter, oldest first.
+func (l *eventLog) list(filter eventFilter) []data.Event {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	events := []data.Event{}
+	for _, e := range l.events {
+		if filter.matches(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func (m *MeasureServer) eventsHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Device string    `form:"device"`
+		Type   string    `form:"type"`
+		From   time.Time `form:"from"`
+		To     time.Time `form:"to"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBind(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if !parsedQueryParameters.To.IsZero() && parsedQueryParameters.To.Before(parsedQueryParameters.From) {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("from must be before to"))
+		return
+	}
+	filter := eventFilter{
+		event: parsedQueryParameters.Type,
+		from:  parsedQueryParameters.From,
+		to:    parsedQueryParameters.To,
+	}
+	if parsedQueryParameters.Device != "" {
+		filter.device = m.ids.canonical(parsedQueryParameters.Device)
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"events": m.Events.list(filter),
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const exportDateFormat = "2006-01-02"
+
+// exportLoop periodically writes the readings of the current and the previous day to one CSV
+// file per device and day in dir. Rewriting the previous day ensures the readings received
+// shortly before midnight end up in its file.
+func (m *MeasureServer) exportLoop(dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		today := time.Now().Truncate(24 * time.Hour)
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+			if err := m.exportDay(dir, day); err != nil {
+				m.Logger.Warnf("unable to export readings of %s: %s", day.Format(exportDateFormat), err)
+			}
+		}
+	}
+}
+
+func (m *MeasureServer) exportDay(dir string, day time.Time) error {
+	devices, err := m.historyDevices()
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		records, err := m.history(device, day, day.Add(24*time.Hour-time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		deviceDir := filepath.Join(dir, url.PathEscape(device))
+		if err := os.MkdirAll(deviceDir, 0o755); err != nil {
+			return err
+		}
+		path := filepath.Join(deviceDir, day.Format(exportDateFormat)+".csv")
+		if err := store.WriteFileAtomic(path, func(w io.Writer) error {
+			return store.WriteCSV(w, records)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MeasureServer) exportHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Format string    `form:"format"`
+		Device string    `form:"device"`
+		From   time.Time `form:"from"`
+		To     time.Time `form:"to"`
+		Tags   []string  `form:"tag"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBind(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	tags, err := parseTagFilter(parsedQueryParameters.Tags)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var devices []string
+	if parsedQueryParameters.Device != "" {
+		devices = []string{m.ids.canonical(parsedQueryParameters.Device)}
+	} else if devices, err = m.historyDevices(); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	var records []store.Record
+	for _, device := range devices {
+		if !m.registry.get(device).matches(tags) {
+			continue
+		}
+		r, err := m.history(device, parsedQueryParameters.From, parsedQueryParameters.To)
+		if err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		records = append(records, r...)
+	}
+
+	var (
+		write       func(io.Writer, []store.Record) error
+		contentType string
+		extension   string
+	)
+	switch parsedQueryParameters.Format {
+	case "", "parquet":
+		write, contentType, extension = store.WriteParquet, "application/vnd.apache.parquet", "parquet"
+	case "csv":
+		write, contentType, extension = store.WriteCSV, "text/csv", "csv"
+	default:
+		abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("unsupported format %q", parsedQueryParameters.Format))
+		return
+	}
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=measure.%s", extension))
+	ctx.Status(http.StatusOK)
+	if err := write(ctx.Writer, records); err != nil {
+		m.Logger.Warnf("unable to write export: %s", err)
+	}
+}
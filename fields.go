@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/finfinack/measure/store"
+)
+
+// parseFields parses a comma separated selection of fields, e.g. temperature,battery,last_seen.
+func parseFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// selectFields returns the selected fields of a device, looked up in the metrics of its reading
+// and its info. The reading, which may be nil, must already be in the unit of the info. Fields
+// the device has no value for are left out.
+func selectFields(info deviceInfo, r *store.Record, fields []string) map[string]any {
+	var all map[string]any
+	if b, err := json.Marshal(info); err == nil {
+		json.Unmarshal(b, &all)
+	}
+	if r != nil {
+		for metric, v := range r.Reading.Metrics() {
+			all[metric] = v
+		}
+	}
+	selected := map[string]any{}
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected
+}
@@ -0,0 +1,15 @@
+package main
+
+import "strings"
+
+// stringList is a flag which can be set multiple times.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
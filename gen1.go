@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gen1Handler is the target of the "report sensor values" action URL of Gen1 Shelly H&T devices.
+// The firmware blindly appends ?hum=..&temp=..&id=.. to the configured URL, so a URL which
+// already has a query ends up with a second question mark. Older firmwares omit the id, in which
+// case a device parameter added to the configured URL is used. Like reporting via GET to the
+// report endpoint it is deprecated and disabled by -reportGET=false.
+func (m *MeasureServer) gen1Handler(ctx *gin.Context) {
+	query, err := url.ParseQuery(strings.ReplaceAll(ctx.Request.URL.RawQuery, "?", "&"))
+	if err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	r := data.ReportStatus{Device: query.Get("id")}
+	if r.Device == "" {
+		r.Device = query.Get("device")
+	}
+	for param, value := range map[string]**float64{"temp": &r.Temperature, "hum": &r.Humidity} {
+		raw := strings.TrimSpace(query.Get(param))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			reportRequests.WithLabelValues("rejected").Inc()
+			abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("invalid value %q of %s", raw, param))
+			return
+		}
+		*value = &v
+	}
+	if r.Device == "" || (r.Temperature == nil && r.Humidity == nil) {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, errors.New("not enough parameters set"))
+		return
+	}
+	if err := r.Validate(); err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if !m.authorized(ctx, r.Device) {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusForbidden, fmt.Errorf("not authorized to report device %q", r.Device))
+		return
+	}
+	msg, err := json.Marshal(r)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	m.record(ctx.Request.Context(), r.Device, json.RawMessage(msg))
+	reportRequests.WithLabelValues("accepted").Inc()
+
+	m.deprecateGET(ctx, r.Device, gen1Endpoint)
+	ctx.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Grafana JSON datasource (simpod-json-datasource / simple-json) API. Targets are named
+// "<device>.<metric>"; a target without a metric selects all metrics of the device.
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+type grafanaQueryRequest struct {
+	Range   grafanaRange `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+	MaxDataPoints int `json:"maxDataPoints"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange `json:"range"`
+	Annotation struct {
+		// Query optionally selects the events of a single device.
+		Query string `json:"query"`
+	} `json:"annotation"`
+}
+
+type grafanaAnnotation struct {
+	Time  int64    `json:"time"` // unix milliseconds
+	Title string   `json:"title"`
+	Text  string   `json:"text,omitempty"`
+	Tags  []string `json:"tags"`
+}
+
+type grafanaTimeserie struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"` // value, unix milliseconds
+}
+
+func (m *MeasureServer) grafanaTestHandler(ctx *gin.Context) {
+	ctx.Status(http.StatusOK)
+}
+
+func (m *MeasureServer) grafanaSearchHandler(ctx *gin.Context) {
+	var req grafanaSearchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && ctx.Request.ContentLength != 0 {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := m.Cache.Items()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	targets := []string{}
+	for device, r := range items {
+		for metric := range r.Reading.Metrics() {
+			target := device + "." + metric
+			if strings.Contains(target, req.Target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	sort.Strings(targets)
+	ctx.JSON(http.StatusOK, targets)
+}
+
+func (m *MeasureServer) grafanaQueryHandler(ctx *gin.Context) {
+	var req grafanaQueryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	series := []grafanaTimeserie{}
+	for _, t := range req.Targets {
+		device, metric := m.grafanaTarget(t.Target)
+		readings, err := m.history(device, req.Range.From, req.Range.To)
+		if err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		byMetric := map[string]*grafanaTimeserie{}
+		var names []string
+		for _, r := range readings {
+			for name, v := range r.Reading.Metrics() {
+				if metric != "" && name != metric {
+					continue
+				}
+				s, ok := byMetric[name]
+				if !ok {
+					s = &grafanaTimeserie{Target: m.displayName(device) + "." + name, Datapoints: [][2]float64{}}
+					byMetric[name] = s
+					names = append(names, name)
+				}
+				s.Datapoints = append(s.Datapoints, [2]float64{v, float64(r.Received.UnixMilli())})
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s := byMetric[name]
+			s.Datapoints = thin(s.Datapoints, req.MaxDataPoints)
+			series = append(series, *s)
+		}
+	}
+	ctx.JSON(http.StatusOK, series)
+}
+
+// grafanaTarget splits a target into device and metric. Targets naming a known device select all
+// its metrics so device IDs containing dots work as well.
+func (m *MeasureServer) grafanaTarget(target string) (string, string) {
+	if _, err := m.Cache.Get(target); err == nil {
+		return target, ""
+	}
+	if i := strings.LastIndex(target, "."); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return target, ""
+}
+
+// grafanaAnnotationsHandler answers annotation queries with the events devices sent in the
+// range, e.g. button presses. The query of the annotation selects a device.
+func (m *MeasureServer) grafanaAnnotationsHandler(ctx *gin.Context) {
+	var req grafanaAnnotationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	filter := eventFilter{from: req.Range.From, to: req.Range.To}
+	if q := strings.TrimSpace(req.Annotation.Query); q != "" {
+		filter.device = m.ids.canonical(q)
+	}
+	annotations := []grafanaAnnotation{}
+	for _, e := range m.Events.list(filter) {
+		annotations = append(annotations, grafanaAnnotation{
+			Time:  e.Time.UnixMilli(),
+			Title: m.displayName(e.Device) + ": " + e.Event,
+			Text:  string(e.Data),
+			Tags:  []string{e.Device, e.Event},
+		})
+	}
+	ctx.JSON(http.StatusOK, annotations)
+}
+
+// thin reduces points to at most max points by keeping every n-th one. The last point is
+// always kept. A max of 0 keeps all points.
+func thin(points [][2]float64, max int) [][2]float64 {
+	if max <= 0 || len(points) <= max {
+		return points
+	}
+	step := (len(points) + max - 1) / max
+	thinned := make([][2]float64, 0, max)
+	for i := len(points) - 1; i >= 0; i -= step {
+		thinned = append(thinned, points[i])
+	}
+	for i, j := 0, len(thinned)-1; i < j; i, j = i+1, j-1 {
+		thinned[i], thinned[j] = thinned[j], thinned[i]
+	}
+	return thinned
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/cache"
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlSchema exposes devices with their current reading, history and aggregates. Temperatures
+// are returned in the requested unit or the server default.
+const graphqlSchema = `
+scalar Time
+
+type Query {
+	# All known devices sorted by ID, optionally only those with all tags given as <key>:<value>.
+	devices(tag: [String!], prefix: String): [Device!]!
+	device(id: ID!): Device
+}
+
+type Device {
+	id: ID!
+	name: String
+	tags: [Tag!]!
+	groups: [String!]!
+	model: String
+	firmware: String
+	lastSeen: Time
+	stale: Boolean!
+	reading(unit: String): Reading
+	history(from: Time, to: Time, unit: String): [Reading!]!
+	# Readings aggregated into buckets of window, e.g. 1h, with fn avg, min or max.
+	aggregate(window: String!, fn: String, metric: String, from: Time, to: Time, unit: String): [Series!]!
+}
+
+type Tag {
+	key: String!
+	value: String!
+}
+
+type Reading {
+	received: Time!
+	temperature: Float
+	humidity: Float
+	battery: Float
+	metrics: [Metric!]!
+	metric(name: String!): Float
+}
+
+type Metric {
+	name: String!
+	value: Float!
+}
+
+type Series {
+	metric: String!
+	buckets: [Bucket!]!
+}
+
+type Bucket {
+	start: Time!
+	value: Float!
+	count: Int!
+}
+`
+
+// newGraphQLSchema returns the GraphQL schema resolved against the server.
+func (m *MeasureServer) newGraphQLSchema() (*graphql.Schema, error) {
+	return graphql.ParseSchema(graphqlSchema, &graphqlResolver{m: m}, graphql.UseFieldResolvers())
+}
+
+// graphqlHandler executes GraphQL queries sent as JSON, e.g.
+// {"query": "{ devices { id reading { temperature } } }"}.
+func graphqlHandler(schema *graphql.Schema) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req struct {
+			Query         string         `json:"query"`
+			OperationName string         `json:"operationName"`
+			Variables     map[string]any `json:"variables"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, schema.Exec(ctx.Request.Context(), req.Query, req.OperationName, req.Variables))
+	}
+}
+
+// graphqlUnit returns the temperature unit requested by a unit argument.
+func graphqlUnit(unit *string) (string, error) {
+	if unit == nil {
+		return parseUnit(*temperatureUnit)
+	}
+	return parseUnit(*unit)
+}
+
+func graphqlTime(t *graphql.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.Time
+}
+
+type graphqlResolver struct {
+	m *MeasureServer
+}
+
+func (r *graphqlResolver) Devices(args struct {
+	Tag    *[]string
+	Prefix *string
+}) ([]*deviceResolver, error) {
+	var filter []string
+	if args.Tag != nil {
+		filter = *args.Tag
+	}
+	tags, err := parseTagFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var prefix string
+	if args.Prefix != nil {
+		prefix = strings.ToLower(*args.Prefix)
+	}
+	items, err := r.m.Cache.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := map[string]*deviceResolver{}
+	for device, rec := range items {
+		devices[device] = &deviceResolver{m: r.m, id: device, cached: &rec, lastSeen: rec.Received}
+	}
+	for device, t := range r.m.seen.items() {
+		d, ok := devices[device]
+		if !ok {
+			d = &deviceResolver{m: r.m, id: device}
+			devices[device] = d
+		}
+		if t.After(d.lastSeen) {
+			d.lastSeen = t
+		}
+	}
+	resolvers := []*deviceResolver{}
+	for device, d := range devices {
+		if strings.HasPrefix(device, prefix) && r.m.registry.get(device).matches(tags) {
+			resolvers = append(resolvers, d)
+		}
+	}
+	sort.Slice(resolvers, func(i, j int) bool { return resolvers[i].id < resolvers[j].id })
+	return resolvers, nil
+}
+
+func (r *graphqlResolver) Device(args struct{ ID graphql.ID }) (*deviceResolver, error) {
+	device := r.m.ids.canonical(string(args.ID))
+	d := &deviceResolver{m: r.m, id: device}
+	rec, err := r.m.Cache.Get(device)
+	switch {
+	case err == nil:
+		d.cached, d.lastSeen = &rec, rec.Received
+	case !errors.Is(err, cache.ErrNotFound):
+		return nil, err
+	}
+	t, seen := r.m.seen.get(device)
+	if d.cached == nil && !seen {
+		return nil, nil
+	}
+	if t.After(d.lastSeen) {
+		d.lastSeen = t
+	}
+	return d, nil
+}
+
+// deviceResolver resolves a device known from its cached reading or its last contact.
+type deviceResolver struct {
+	m        *MeasureServer
+	id       string
+	cached   *store.Record
+	lastSeen time.Time
+}
+
+// optional returns a pointer to s or nil if it is empty as GraphQL distinguishes null.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (d *deviceResolver) ID() graphql.ID {
+	return graphql.ID(d.id)
+}
+
+func (d *deviceResolver) Name() *string {
+	return optional(d.m.registry.get(d.id).Name)
+}
+
+type graphqlTag struct {
+	Key   string
+	Value string
+}
+
+func (d *deviceResolver) Tags() []graphqlTag {
+	tags := []graphqlTag{}
+	for k, v := range d.m.registry.get(d.id).Tags {
+		tags = append(tags, graphqlTag{Key: k, Value: v})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags
+}
+
+func (d *deviceResolver) Groups() []string {
+	if groups := d.m.registry.get(d.id).Groups; groups != nil {
+		return groups
+	}
+	return []string{}
+}
+
+func (d *deviceResolver) Model() *string {
+	if d.cached == nil {
+		return nil
+	}
+	return optional(d.cached.Reading.Model)
+}
+
+func (d *deviceResolver) Firmware() *string {
+	if d.cached == nil {
+		return nil
+	}
+	return optional(d.cached.Reading.Firmware)
+}
+
+func (d *deviceResolver) LastSeen() *graphql.Time {
+	if d.lastSeen.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: d.lastSeen}
+}
+
+func (d *deviceResolver) Stale() bool {
+	return time.Since(d.lastSeen) > *staleAfter
+}
+
+func (d *deviceResolver) Reading(args struct{ Unit *string }) (*readingResolver, error) {
+	unit, err := graphqlUnit(args.Unit)
+	if err != nil {
+		return nil, err
+	}
+	if d.cached == nil {
+		return nil, nil
+	}
+	return &readingResolver{outputRecord(*d.cached, unit)}, nil
+}
+
+func (d *deviceResolver) History(args struct {
+	From *graphql.Time
+	To   *graphql.Time
+	Unit *string
+}) ([]*readingResolver, error) {
+	unit, err := graphqlUnit(args.Unit)
+	if err != nil {
+		return nil, err
+	}
+	records, err := d.m.history(d.id, graphqlTime(args.From), graphqlTime(args.To))
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]*readingResolver, len(records))
+	for i, rec := range outputRecords(records, unit) {
+		readings[i] = &readingResolver{rec}
+	}
+	return readings, nil
+}
+
+type graphqlBucket struct {
+	Start graphql.Time
+	Value float64
+	Count int32
+}
+
+type graphqlSeries struct {
+	Metric  string
+	Buckets []graphqlBucket
+}
+
+func (d *deviceResolver) Aggregate(args struct {
+	Window string
+	Fn     *string
+	Metric *string
+	From   *graphql.Time
+	To     *graphql.Time
+	Unit   *string
+}) ([]graphqlSeries, error) {
+	window, err := time.ParseDuration(args.Window)
+	if err != nil {
+		return nil, err
+	}
+	var fn, only string
+	if args.Fn != nil {
+		fn = *args.Fn
+	}
+	if args.Metric != nil {
+		only = *args.Metric
+	}
+	fn, from, to, err := aggregateRange(window, fn, graphqlTime(args.From), graphqlTime(args.To), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	unit, err := graphqlUnit(args.Unit)
+	if err != nil {
+		return nil, err
+	}
+	records, err := d.m.history(d.id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := []graphqlSeries{}
+	for metric, points := range aggregate(records, window, fn, only, unit) {
+		s := graphqlSeries{Metric: metric, Buckets: make([]graphqlBucket, len(points))}
+		for i, p := range points {
+			s.Buckets[i] = graphqlBucket{Start: graphql.Time{Time: p.Start}, Value: p.Value, Count: int32(p.Count)}
+		}
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Metric < series[j].Metric })
+	return series, nil
+}
+
+// readingResolver resolves a record already converted to the requested unit.
+type readingResolver struct {
+	r store.Record
+}
+
+func (r *readingResolver) Received() graphql.Time {
+	return graphql.Time{Time: r.r.Received}
+}
+
+func (r *readingResolver) Temperature() *float64 {
+	return r.r.Reading.Temperature
+}
+
+func (r *readingResolver) Humidity() *float64 {
+	return r.r.Reading.Humidity
+}
+
+func (r *readingResolver) Battery() *float64 {
+	return r.r.Reading.Battery
+}
+
+type graphqlMetric struct {
+	Name  string
+	Value float64
+}
+
+func (r *readingResolver) Metrics() []graphqlMetric {
+	metrics := []graphqlMetric{}
+	for name, v := range r.r.Reading.Metrics() {
+		metrics = append(metrics, graphqlMetric{Name: name, Value: v})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
+
+func (r *readingResolver) Metric(args struct{ Name string }) *float64 {
+	v, ok := r.r.Reading.Metrics()[args.Name]
+	if !ok {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/finfinack/measure/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// groupStats aggregates the current values of a metric across the devices of a group.
+type groupStats struct {
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"`
+	Devices int     `json:"devices"`
+}
+
+// collectGroup responds with the minimum, maximum and average of every metric across the cached
+// readings of the devices in a group. Temperatures are returned in unit.
+func (m *MeasureServer) collectGroup(ctx *gin.Context, group, unit string) {
+	members := m.registry.members(group)
+	if len(members) == 0 {
+		abortWithError(ctx, http.StatusNotFound, errors.New("group has no devices"))
+		return
+	}
+
+	stats := map[string]*groupStats{}
+	devices := []string{}
+	for _, device := range members {
+		r, err := m.Cache.Get(device)
+		if errors.Is(err, cache.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		devices = append(devices, device)
+		for metric, v := range r.Reading.InUnit(unit).Metrics() {
+			s, ok := stats[metric]
+			if !ok {
+				s = &groupStats{Min: math.Inf(1), Max: math.Inf(-1)}
+				stats[metric] = s
+			}
+			s.Min = math.Min(s.Min, v)
+			s.Max = math.Max(s.Max, v)
+			// Avg holds the sum until all devices are seen.
+			s.Avg += v
+			s.Devices++
+		}
+	}
+	for _, s := range stats {
+		s.Avg /= float64(s.Devices)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"group":   group,
+		"devices": devices,
+		"metrics": stats,
+		"unit":    unit,
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/finfinack/measure/cache"
+	"github.com/finfinack/measure/measurepb"
+	"github.com/finfinack/measure/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var grpcPort = flag.Int("grpcPort", 0, "Port the gRPC API listens on. Uses -tlsCert and -tlsKey if set. 0 disables the gRPC API.")
+
+// grpcServer implements the gRPC API on top of the same cache and recording as the HTTP API.
+type grpcServer struct {
+	measurepb.UnimplementedMeasureServer
+
+	m *MeasureServer
+}
+
+// serveGRPC starts the gRPC API on port.
+func (m *MeasureServer) serveGRPC(port int, tlsCert, tlsKey string) error {
+	var opts []grpc.ServerOption
+	if tlsCert != "" && tlsKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(tlsCert, tlsKey)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	opts = append(opts, grpc.UnaryInterceptor(m.grpcUnaryAuth), grpc.StreamInterceptor(m.grpcStreamAuth))
+	s := grpc.NewServer(opts...)
+	measurepb.RegisterMeasureServer(s, &grpcServer{m: m})
+	go func() {
+		if err := s.Serve(l); err != nil {
+			m.Logger.Warnf("gRPC server stopped: %s", err)
+		}
+	}()
+	return nil
+}
+
+// grpcScopes are the scopes calls of the RPCs require, see authenticate.
+var grpcScopes = map[string]string{
+	measurepb.Measure_Report_FullMethodName:         scopeWrite,
+	measurepb.Measure_Collect_FullMethodName:        scopeRead,
+	measurepb.Measure_StreamReadings_FullMethodName: scopeRead,
+}
+
+// grantContextKey is the key of the grant of a call on its context.
+type grantContextKey struct{}
+
+// grpcCredentials returns the credentials presented in the authorization and x-api-key
+// metadata of a call.
+func grpcCredentials(ctx context.Context) authCredentials {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var c authCredentials
+	if v := md.Get("authorization"); len(v) > 0 {
+		c.authorization = v[0]
+	}
+	if v := md.Get(apiKeyHeader); len(v) > 0 {
+		c.key = v[0]
+	}
+	return c
+}
+
+// authenticateGRPC checks the credentials of a call of method and returns its context holding
+// the grant.
+func (m *MeasureServer) authenticateGRPC(ctx context.Context, method string) (context.Context, error) {
+	scope, ok := grpcScopes[method]
+	if !ok {
+		return ctx, nil
+	}
+	g, code, err := m.authenticate(grpcCredentials(ctx), scope)
+	switch {
+	case code == http.StatusUnauthorized:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return context.WithValue(ctx, grantContextKey{}, g), nil
+}
+
+func (m *MeasureServer) grpcUnaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := m.authenticateGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (m *MeasureServer) grpcStreamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := m.authenticateGRPC(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func toReading(r store.Record) *measurepb.Reading {
+	return &measurepb.Reading{
+		Device:   r.Device,
+		Received: timestamppb.New(r.Received),
+		Metrics:  r.Reading.Metrics(),
+	}
+}
+
+func (s *grpcServer) Report(ctx context.Context, req *measurepb.ReportRequest) (*measurepb.ReportResponse, error) {
+	reading := reportReading{
+		ID:          req.GetDevice(),
+		Temperature: req.Temperature,
+		Humidity:    req.Humidity,
+		Battery:     req.Battery,
+	}
+	r, err := reading.status()
+	if err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if g, _ := ctx.Value(grantContextKey{}).(grant); !s.m.mayReport(g, r.Device) {
+		reportRequests.WithLabelValues("rejected").Inc()
+		return nil, status.Errorf(codes.PermissionDenied, "not authorized to report device %q", r.Device)
+	}
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	received := time.Now()
+	if req.GetTimestamp() != nil {
+		received = req.GetTimestamp().AsTime()
+	}
+	s.m.recordAt(ctx, r.Device, json.RawMessage(msg), received)
+	reportRequests.WithLabelValues("accepted").Inc()
+	return &measurepb.ReportResponse{}, nil
+}
+
+func (s *grpcServer) Collect(_ context.Context, req *measurepb.CollectRequest) (*measurepb.CollectResponse, error) {
+	if req.GetDevice() != "" {
+		r, err := s.m.Cache.Get(s.m.ids.canonical(req.GetDevice()))
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &measurepb.CollectResponse{Readings: []*measurepb.Reading{toReading(r)}}, nil
+	}
+
+	items, err := s.m.Cache.Items()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &measurepb.CollectResponse{}
+	for _, r := range items {
+		resp.Readings = append(resp.Readings, toReading(r))
+	}
+	slices.SortFunc(resp.Readings, func(a, b *measurepb.Reading) int {
+		switch {
+		case a.Device < b.Device:
+			return -1
+		case a.Device > b.Device:
+			return 1
+		}
+		return 0
+	})
+	return resp, nil
+}
+
+func (s *grpcServer) StreamReadings(req *measurepb.StreamReadingsRequest, stream grpc.ServerStreamingServer[measurepb.Reading]) error {
+	readings, unsubscribe := s.m.hub.subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case r := <-readings:
+			if len(req.GetDevices()) > 0 && !slices.Contains(req.GetDevices(), r.Device) {
+				continue
+			}
+			if err := stream.Send(toReading(r)); err != nil {
+				return err
+			}
+		}
+	}
+}
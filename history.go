@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *MeasureServer) historyHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Device string    `form:"device"`
+		From   time.Time `form:"from"`
+		To     time.Time `form:"to"`
+		Format string    `form:"format"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBind(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if parsedQueryParameters.Device == "" {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("device not set"))
+		return
+	}
+	parsedQueryParameters.Device = m.ids.canonical(parsedQueryParameters.Device)
+
+	format, err := tabularFormat(ctx, parsedQueryParameters.Format)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	readings, err := m.history(parsedQueryParameters.Device, parsedQueryParameters.From, parsedQueryParameters.To)
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	readings = outputRecords(readings, unit)
+	if format != "" {
+		m.writeTabular(ctx, format, "history", readings)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"device":   parsedQueryParameters.Device,
+		"readings": readings,
+		"unit":     unit,
+	})
+}
+
+// history returns the readings of a device in [from, to] from the persistent store if it keeps
+// history and from the in-memory history otherwise.
+// Archived readings are transparently included.
+func (m *MeasureServer) history(device string, from, to time.Time) ([]store.Record, error) {
+	r, ok := m.Store.(store.Ranger)
+	if !ok {
+		return m.History.Range(device, from, to), nil
+	}
+	records, err := r.Range(device, from, to)
+	if err != nil || m.Archive == nil {
+		return records, err
+	}
+	archived, err := m.Archive.Range(device, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(archived, records...), nil
+}
+
+// historyDevices returns the IDs of all devices with history, sorted. Devices only known to
+// the persistent store, e.g. after a restart, are included if it keeps history.
+func (m *MeasureServer) historyDevices() ([]string, error) {
+	devices := m.History.Devices()
+	if _, ok := m.Store.(store.Ranger); !ok {
+		return devices, nil
+	}
+	latest, err := m.Store.Latest()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range latest {
+		if !slices.Contains(devices, r.Device) {
+			devices = append(devices, r.Device)
+		}
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+const (
+	defaultReadingsLimit = 50
+	maxReadingsLimit     = 1000
+)
+
+// readingsHandler returns the most recent readings of a device, oldest first, e.g. for
+// sparklines. They are served from the in-memory history if it holds enough readings and
+// otherwise queried from the store.
+func (m *MeasureServer) readingsHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Limit int `form:"limit"`
+	}
+
+	parsedQueryParameters := queryParameters{Limit: defaultReadingsLimit}
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	limit := parsedQueryParameters.Limit
+	if limit <= 0 || limit > maxReadingsLimit {
+		abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxReadingsLimit))
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	device := m.ids.canonical(ctx.Param("id"))
+	readings := m.History.Range(device, time.Time{}, time.Time{})
+	if l, ok := m.Store.(store.Laster); ok && len(readings) < limit {
+		if readings, err = l.Last(device, limit); err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	readings = readings[max(len(readings)-limit, 0):]
+	ctx.JSON(http.StatusOK, gin.H{
+		"device":   device,
+		"readings": outputRecords(readings, unit),
+		"unit":     unit,
+	})
+}
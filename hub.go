@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/finfinack/measure/store"
+)
+
+const subscriberBuffer = 64
+
+// hub fans out recorded readings to subscribers such as streaming API clients. Readings are
+// dropped for subscribers which do not keep up.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan store.Record]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: map[chan store.Record]struct{}{},
+	}
+}
+
+// subscribe returns a channel receiving all published readings and a function to unsubscribe
+// which closes the channel.
+func (h *hub) subscribe() (<-chan store.Record, func()) {
+	ch := make(chan store.Record, subscriberBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *hub) publish(r store.Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+)
+
+// runImport loads historical readings from CSV files into the configured persistent store.
+// Devices are imported by their canonical ID as if the readings were received.
+func runImport(files []string) error {
+	if len(files) == 0 {
+		return errors.New("no CSV files to import specified")
+	}
+	ids, err := newDeviceIDs(deviceAliases)
+	if err != nil {
+		return err
+	}
+	st, err := newStore(*storeType, *storePath, *storeTimescale)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return errors.New("importing requires a persistent store, set -store")
+	}
+	defer st.Close()
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		records, err := store.ReadCSV(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to parse %q: %s", file, err)
+		}
+		for _, r := range records {
+			if device := ids.canonical(r.Device); device != r.Device {
+				r = store.NewRecord(device, r.Received, data.WithDevice(r.Payload, device))
+			}
+			if err := st.Save(r); err != nil {
+				return fmt.Errorf("unable to import reading of %q: %s", r.Device, err)
+			}
+		}
+		fmt.Printf("Imported %d readings from %q\n", len(records), file)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ingestLocalTime is the format of timestamps without offset, e.g. of Tasmota.
+const ingestLocalTime = "2006-01-02T15:04:05"
+
+// ingestProfileConfig maps the JSON documents sent by a kind of device onto readings, e.g.
+//
+//	{
+//	  "tasmota": {
+//	    "device": "$.Device",
+//	    "timestamp": "$.Time",
+//	    "metrics": {"temperature": "$.AM2301.Temperature", "humidity": "$.AM2301.Humidity"}
+//	  }
+//	}
+//
+// Without a device path, the device is taken from the device query parameter. Timestamps are
+// Unix seconds or RFC3339, without an offset like Tasmota sends them in local time, and default
+// to the time the document is received.
+type ingestProfileConfig struct {
+	Device    string            `json:"device"`
+	Timestamp string            `json:"timestamp"`
+	Metrics   map[string]string `json:"metrics"`
+}
+
+type ingestProfile struct {
+	device    data.Path // optional
+	timestamp data.Path // optional
+	metrics   map[string]data.Path
+}
+
+// loadIngestProfiles reads the ingest profiles by name from a JSON file.
+func loadIngestProfiles(path string) (map[string]*ingestProfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]ingestProfileConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse %q: %s", path, err)
+	}
+
+	profiles := map[string]*ingestProfile{}
+	for name, c := range cfg {
+		p := &ingestProfile{metrics: map[string]data.Path{}}
+		if c.Device != "" {
+			if p.device, err = data.ParsePath(c.Device); err != nil {
+				return nil, fmt.Errorf("profile %q: %s", name, err)
+			}
+		}
+		if c.Timestamp != "" {
+			if p.timestamp, err = data.ParsePath(c.Timestamp); err != nil {
+				return nil, fmt.Errorf("profile %q: %s", name, err)
+			}
+		}
+		if len(c.Metrics) == 0 {
+			return nil, fmt.Errorf("profile %q maps no metrics", name)
+		}
+		for metric, expr := range c.Metrics {
+			if !data.ValidMetric(metric) {
+				return nil, fmt.Errorf("profile %q: invalid metric %q", name, metric)
+			}
+			if p.metrics[metric], err = data.ParsePath(expr); err != nil {
+				return nil, fmt.Errorf("profile %q: %s", name, err)
+			}
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// reading maps a document onto a reading. Documents without any of the mapped metrics are
+// rejected.
+func (p *ingestProfile) reading(doc any, device string) (reportReading, error) {
+	if p.device != nil {
+		var ok bool
+		if device, ok = p.device.StringValue(doc); !ok {
+			return reportReading{}, fmt.Errorf("no device ID at %s", p.device)
+		}
+	}
+	r := reportReading{ID: device}
+	for metric, path := range p.metrics {
+		v, ok := path.Float(doc)
+		if !ok {
+			continue
+		}
+		r.set(metric, v)
+	}
+	if p.timestamp == nil {
+		return r, nil
+	}
+	if ts, ok := p.timestamp.Float(doc); ok {
+		r.Timestamp = &ts
+	} else if s, ok := p.timestamp.StringValue(doc); ok {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t, err = time.ParseInLocation(ingestLocalTime, s, time.Local)
+		}
+		if err != nil {
+			return reportReading{}, fmt.Errorf("invalid timestamp %q", s)
+		}
+		ts := float64(t.UnixMilli()) / 1000
+		r.Timestamp = &ts
+	}
+	return r, nil
+}
+
+// ingestHandler accepts arbitrary JSON documents, or arrays of them, and maps them onto
+// readings using the profile named in the path.
+func (m *MeasureServer) ingestHandler(profiles map[string]*ingestProfile) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		type queryParameters struct {
+			Device string `form:"device"`
+		}
+
+		profile, ok := profiles[ctx.Param("profile")]
+		if !ok {
+			abortWithError(ctx, http.StatusNotFound, fmt.Errorf("unknown ingest profile %q", ctx.Param("profile")))
+			return
+		}
+		var parsedQueryParameters queryParameters
+		if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxWriteSize))
+		if err != nil {
+			abortWithError(ctx, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		docs, ok := doc.([]any)
+		if !ok {
+			docs = []any{doc}
+		}
+
+		now := time.Now()
+		readings := make([]reportReading, len(docs))
+		msgs := make([]json.RawMessage, len(docs))
+		for i, d := range docs {
+			r, err := profile.reading(d, parsedQueryParameters.Device)
+			if err == nil {
+				var status data.ReportStatus
+				if status, err = r.status(); err == nil {
+					msgs[i], err = json.Marshal(status)
+				}
+			}
+			if err != nil {
+				reportRequests.WithLabelValues("rejected").Inc()
+				abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("document %d: %s", i, err))
+				return
+			}
+			if !m.authorized(ctx, r.ID) {
+				reportRequests.WithLabelValues("rejected").Inc()
+				abortWithError(ctx, http.StatusForbidden, fmt.Errorf("document %d: not authorized to report device %q", i, r.ID))
+				return
+			}
+			readings[i] = r
+		}
+		for i, r := range readings {
+			m.recordAt(ctx.Request.Context(), r.ID, msgs[i], r.received(now))
+		}
+		reportRequests.WithLabelValues("accepted").Add(float64(len(readings)))
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"accepted": len(readings),
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	jwtSecret     = flag.String("jwtSecret", "", "Shared secret bearer tokens are validated with as HMAC signed JWTs. Mutually exclusive with -jwksURL.")
+	jwksURL       = flag.String("jwksURL", "", "URL of the JWKS bearer tokens are validated against as JWTs, e.g. of an identity provider. Mutually exclusive with -jwtSecret.")
+	jwtIssuer     = flag.String("jwtIssuer", "", "Issuer JWTs must have. Empty accepts any issuer.")
+	jwtAudience   = flag.String("jwtAudience", "", "Audience JWTs must have. Empty accepts any audience.")
+	jwtScopeClaim = flag.String("jwtScopeClaim", "scope", "Claim of JWTs holding the granted scopes read, write and admin as space separated string or list. Admin implies the other scopes.")
+)
+
+// Scopes granted by JWTs.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+	scopeAdmin = "admin"
+)
+
+// errMissingScope is returned for valid tokens which lack the required scope.
+var errMissingScope = errors.New("token lacks scope")
+
+// jwtValidator validates bearer tokens as JWTs signed with a shared secret or a key of a JWKS.
+type jwtValidator struct {
+	keyfunc    jwt.Keyfunc
+	parser     *jwt.Parser
+	scopeClaim string
+}
+
+// newJWTValidator returns a validator for either secret or the JWKS at jwksURL, or nil if both
+// are empty. Issuer and audience are only checked if set.
+func newJWTValidator(secret, jwksURL, issuer, audience, scopeClaim string) (*jwtValidator, error) {
+	var opts []jwt.ParserOption
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	v := &jwtValidator{scopeClaim: scopeClaim}
+	switch {
+	case secret != "" && jwksURL != "":
+		return nil, errors.New("only one of a secret and a JWKS URL can be set")
+	case secret != "":
+		v.keyfunc = func(*jwt.Token) (any, error) { return []byte(secret), nil }
+		opts = append(opts, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+	case jwksURL != "":
+		k, err := keyfunc.NewDefault([]string{jwksURL})
+		if err != nil {
+			return nil, err
+		}
+		v.keyfunc = k.Keyfunc
+		opts = append(opts, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}))
+	default:
+		return nil, nil
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// scopes validates token and returns the scopes it grants.
+func (v *jwtValidator) scopes(token string) ([]string, error) {
+	var claims jwt.MapClaims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.keyfunc); err != nil {
+		return nil, err
+	}
+	switch s := claims[v.scopeClaim].(type) {
+	case string:
+		return strings.Fields(s), nil
+	case []any:
+		var scopes []string
+		for _, scope := range s {
+			if scope, ok := scope.(string); ok {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes, nil
+	default:
+		return nil, nil
+	}
+}
+
+// authorize validates token and returns errMissingScope if it doesn't grant scope.
+func (v *jwtValidator) authorize(token, scope string) error {
+	scopes, err := v.scopes(token)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(scopes, scope) && !slices.Contains(scopes, scopeAdmin) {
+		return fmt.Errorf("%w %q", errMissingScope, scope)
+	}
+	return nil
+}
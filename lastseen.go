@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+)
+
+var staleAfter = flag.Duration("staleAfter", time.Hour, "Duration without contact after which a device is reported as stale.")
+
+// lastSeen tracks the last contact with every device since startup. Unlike cached readings,
+// entries never expire so devices which went silent remain visible.
+type lastSeen struct {
+	mu      sync.Mutex
+	devices map[string]time.Time
+}
+
+func newLastSeen() *lastSeen {
+	return &lastSeen{
+		devices: map[string]time.Time{},
+	}
+}
+
+func (s *lastSeen) touch(device string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.devices[device]) {
+		s.devices[device] = t
+	}
+}
+
+func (s *lastSeen) delete(device string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, device)
+}
+
+func (s *lastSeen) get(device string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.devices[device]
+	return t, ok
+}
+
+func (s *lastSeen) items() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make(map[string]time.Time, len(s.devices))
+	for device, t := range s.devices {
+		items[device] = t
+	}
+	return items
+}
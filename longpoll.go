@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/finfinack/measure/store"
+)
+
+// maxCollectWait limits how long collect requests may block waiting for updates.
+const maxCollectWait = 5 * time.Minute
+
+// waitForUpdate blocks until a device matching match has a cached reading received after since,
+// wait passed or ctx is done.
+func (m *MeasureServer) waitForUpdate(ctx context.Context, since time.Time, wait time.Duration, match func(string) bool) error {
+	// Subscribe before looking at the cache so readings in between are not missed.
+	readings, unsubscribe := m.hub.subscribe()
+	defer unsubscribe()
+
+	items, err := m.Cache.Items()
+	if err != nil {
+		return err
+	}
+	for device, r := range items {
+		if r.Received.After(since) && match(device) {
+			return nil
+		}
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timeout.C:
+			return nil
+		case r, ok := <-readings:
+			if !ok {
+				return nil
+			}
+			if r.Received.After(since) && match(r.Device) {
+				return nil
+			}
+		}
+	}
+}
+
+// latestReceived returns when the newest reading of devices was received, or since if there is
+// none. Clients pass it as since of their next request.
+func latestReceived(items map[string]store.Record, devices []string, since time.Time) time.Time {
+	latest := since
+	for _, device := range devices {
+		if r, ok := items[device]; ok && r.Received.After(latest) {
+			latest = r.Received
+		}
+	}
+	return latest
+}
@@ -1,20 +1,35 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/finfinack/measure/archive"
+	"github.com/finfinack/measure/cache"
 	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/sink"
+	"github.com/finfinack/measure/store"
 
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/finfinack/logger/logging"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	ttlcache "github.com/jellydator/ttlcache/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -23,12 +38,82 @@ var (
 	tlsKey   = flag.String("tlsKey", "", "Path to TLS Key. If this and -tlsCert is specified, service runs as TLS server.")
 	cacheTTL = flag.Duration("cacheTTL", 3*time.Hour, "Duration for which to keep the entries in cache.")
 	logLevel = flag.String("loglevel", "INFO", "Log level to use.")
+
+	cacheType     = flag.String("cache", "memory", "Cache holding the latest reading per device. Supported: memory, redis.")
+	redisAddr     = flag.String("redisAddr", "localhost:6379", "Address of the Redis server used as cache.")
+	redisPassword = flag.String("redisPassword", "", "Password of the Redis server used as cache.")
+	redisDB       = flag.Int("redisDB", 0, "Redis database used as cache.")
+	redisPrefix   = flag.String("redisPrefix", "measure:", "Prefix of all keys written to Redis.")
+
+	storeType      = flag.String("store", "", "Persistent store to write readings to. Supported: sqlite, postgres, bolt. Empty disables persistence.")
+	storePath      = flag.String("storePath", "measure.db", "Path to the database file (sqlite, bolt) or connection string (postgres) of the persistent store.")
+	storeTimescale = flag.Bool("storeTimescale", false, "Convert the postgres readings table into a TimescaleDB hypertable.")
+
+	snapshotPath     = flag.String("snapshotPath", "", "Path to the file the cache is periodically snapshotted to and restored from on startup. Empty disables snapshots.")
+	snapshotInterval = flag.Duration("snapshotInterval", 5*time.Minute, "Interval in which the cache is snapshotted.")
+
+	walPath     = flag.String("walPath", "", "Path to the write-ahead log all incoming readings are appended to. Empty disables the log.")
+	walMaxSize  = flag.Int64("walMaxSize", 10<<20, "Size in bytes after which the write-ahead log is rotated.")
+	walMaxFiles = flag.Int("walMaxFiles", 5, "Number of rotated write-ahead log files to keep.")
+	walReplay   = flag.Bool("walReplay", false, "Replay the write-ahead log into the cache on startup.")
+
+	retentionRaw         = flag.Duration("retentionRaw", 0, "Age after which raw readings in the persistent store are downsampled, e.g. 168h. Zero keeps raw readings forever.")
+	retentionWindow      = flag.Duration("retentionWindow", 5*time.Minute, "Size of the buckets raw readings are downsampled into.")
+	retentionDownsampled = flag.Duration("retentionDownsampled", 90*24*time.Hour, "Age after which downsampled readings are deleted. Zero keeps them forever.")
+	compactionInterval   = flag.Duration("compactionInterval", time.Hour, "Interval in which the persistent store is compacted.")
+
+	exportDir      = flag.String("exportDir", "", "Directory daily (UTC) CSV files of all readings per device are written to. Empty disables the export.")
+	exportInterval = flag.Duration("exportInterval", time.Hour, "Interval in which the CSV files are updated.")
+
+	adminToken = flag.String("adminToken", "", "Bearer token required to access the admin endpoints. Empty disables the admin endpoints.")
+
+	archiveEndpoint  = flag.String("archiveEndpoint", "", "S3 compatible endpoint (host:port) old readings are archived to, e.g. s3.amazonaws.com or storage.googleapis.com. Empty disables archival.")
+	archiveBucket    = flag.String("archiveBucket", "measure", "Bucket readings are archived to.")
+	archivePrefix    = flag.String("archivePrefix", "", "Prefix of all archived objects.")
+	archiveAccessKey = flag.String("archiveAccessKey", "", "Access key of the archive bucket.")
+	archiveSecretKey = flag.String("archiveSecretKey", "", "Secret key of the archive bucket.")
+	archiveTLS       = flag.Bool("archiveTLS", true, "Use TLS to connect to the archive endpoint.")
+	archiveAfter     = flag.Duration("archiveAfter", 30*24*time.Hour, "Age after which readings are moved from the persistent store to the archive.")
+	archiveInterval  = flag.Duration("archiveInterval", 24*time.Hour, "Interval in which old readings are archived.")
+
+	dedupWindow = flag.Duration("dedupWindow", 10*time.Minute, "Readings repeating the values and device timestamp of the last stored reading within this window are not stored. Zero disables de-duplication.")
+
+	otlpEndpoint = flag.String("otlpEndpoint", "", "OTLP/HTTP endpoint (host:port) traces are exported to. Empty disables tracing.")
+	otlpInsecure = flag.Bool("otlpInsecure", false, "Export traces and metrics without TLS.")
+
+	otlpMetricsEndpoint = flag.String("otlpMetricsEndpoint", "", "OTLP/gRPC endpoint (host:port) device gauges are pushed to. Empty disables pushing metrics.")
+	otlpMetricsInterval = flag.Duration("otlpMetricsInterval", time.Minute, "Interval in which device gauges are pushed via OTLP.")
+
+	ingestProfiles = flag.String("ingestProfiles", "", "Path to a JSON file with mappings of arbitrary JSON documents onto readings, served on /measure/v1/ingest/<profile>.")
+
+	historyDepth = flag.Int("historyDepth", 1000, "Number of readings to keep in memory per device for the history endpoint. Zero keeps none.")
 )
 
 const (
-	wsEndpoint      = "/measure/v1/ws"
-	collectEndpoint = "/measure/v1/collect"
-	reportEndpoint  = "/measure/v1/report"
+	wsEndpoint        = "/measure/v1/ws"
+	collectEndpoint   = "/measure/v1/collect"
+	reportEndpoint    = "/measure/v1/report"
+	historyEndpoint   = "/measure/v1/history"
+	exportEndpoint    = "/measure/v1/export"
+	adminEndpoint     = "/measure/v1/admin"
+	metricsEndpoint   = "/metrics"
+	writeEndpoint     = "/measure/v1/write"
+	grafanaEndpoint   = "/measure/v1/grafana"
+	gen1Endpoint      = "/measure/v1/gen1"
+	discoveryEndpoint = "/measure/v1/discovery"
+	ingestEndpoint    = "/measure/v1/ingest/:profile"
+	ttnEndpoint       = "/measure/v1/ttn"
+	eventsEndpoint    = "/measure/v1/events"
+	receiveEndpoint   = "/measure/v1/remote_write"
+	devicesEndpoint   = "/measure/v1/devices"
+	aggregateEndpoint = "/measure/v1/aggregate"
+	streamEndpoint    = "/measure/v1/stream"
+	subscribeEndpoint = "/measure/v1/subscribe"
+	openAPIEndpoint   = "/measure/v1/openapi.json"
+	docsEndpoint      = "/measure/v1/docs"
+	apiV2Endpoint     = "/measure/v2"
+	graphqlEndpoint   = "/measure/v1/graphql"
+	diffEndpoint      = "/measure/v1/diff"
 )
 
 var (
@@ -36,9 +121,204 @@ var (
 )
 
 type MeasureServer struct {
-	Cache  *ttlcache.Cache
-	Server *http.Server
-	Logger *logging.Logger
+	Cache   cache.Cache
+	Store   store.Store // optional
+	WAL     *store.WAL  // optional
+	History *store.History
+	Archive *archive.Archive // optional
+	Events  *eventLog
+	// trendLimits holds the change per hour below which a metric is steady.
+	trendLimits map[string]float64
+	// deprecatedGET holds the devices already warned about reporting via GET.
+	deprecatedGET sync.Map
+	// calibrations holds the corrections applied to the readings of a device.
+	calibrations map[string]data.Calibrations
+	plausibility *plausibility
+	registry     *registry
+	ids          *deviceIDs
+	seen         *lastSeen
+	rolling      *rolling
+	comfort      *comfort
+	spikes       *spikeFilter
+	virtual      map[string]*virtualDevice
+	dedup        *deduper
+	basicAuth    *basicCredentials
+	hub          *hub
+	jwt          *jwtValidator // optional
+	ttl          atomic.Int64  // of readings cached from now on in nanoseconds, see cacheTTLHandler
+	Sinks        []sink.Sink
+	Server       *http.Server
+	Logger       *logging.Logger
+}
+
+// record caches the latest reading of a device and persists it if a store is configured.
+// Duplicate readings are only cached.
+func (m *MeasureServer) record(ctx context.Context, device string, payload json.RawMessage) {
+	m.recordAt(ctx, device, payload, time.Now())
+}
+
+// recordAt records a reading received at the given time. Readings older than the cached one,
+// e.g. from devices flushing buffered readings, are not cached.
+func (m *MeasureServer) recordAt(ctx context.Context, device string, payload json.RawMessage, received time.Time) {
+	device = m.ids.canonical(device)
+	m.recordCalibrated(ctx, device, m.calibrate(device, payload), received)
+}
+
+// recordCalibrated records a reading whose values are already calibrated, e.g. because only
+// the new values were calibrated before merging them with the cached reading. Devices are
+// recorded by their canonical ID, which also replaces the ID in the payload.
+func (m *MeasureServer) recordCalibrated(ctx context.Context, device string, payload json.RawMessage, received time.Time) {
+	device = m.ids.canonical(device)
+	payload = data.WithDevice(payload, device)
+	_, span := tracer.Start(ctx, "record", trace.WithAttributes(attribute.String("device", device)))
+	defer span.End()
+
+	r := store.NewRecord(device, received, payload)
+	if m.ids.learn(r.Reading.MAC, device) {
+		m.Logger.Infof("recording MAC %s as %q", r.Reading.MAC, device)
+	}
+	m.seen.touch(device, time.Now())
+	payload, ok := m.checkPlausible(r)
+	if !ok {
+		return
+	}
+	r = store.NewRecord(device, received, payload)
+	if spikes := m.spikes.spikes(r); len(spikes) > 0 {
+		for _, metric := range spikes {
+			spikeReadings.WithLabelValues(metric).Inc()
+		}
+		m.Logger.Infof("dropping spike of %v of %q", spikes, device)
+		return
+	}
+	if m.WAL != nil {
+		if err := m.WAL.Append(r); err != nil {
+			m.Logger.Warnf("unable to append reading of %q to write-ahead log: %s", device, err)
+		}
+	}
+	if c, err := m.Cache.Get(device); err != nil || !c.Received.After(received) {
+		if err := m.Cache.Set(r); err != nil {
+			m.Logger.Warnf("unable to cache reading of %q: %s", device, err)
+		}
+		defer m.updateVirtual(ctx, device, received)
+	}
+	if m.dedup.duplicate(r) {
+		m.Logger.Debugf("skipping duplicate reading of %q", device)
+		return
+	}
+	m.History.Add(r)
+	m.rolling.add(device, r.Received, r.Reading.Metrics())
+	m.checkComfort(r)
+	m.hub.publish(r)
+	for _, s := range m.Sinks {
+		if err := s.Write(r); err != nil {
+			m.Logger.Warnf("unable to export reading of %q: %s", device, err)
+		}
+	}
+	if m.Store == nil {
+		return
+	}
+	if err := m.Store.Save(r); err != nil {
+		span.RecordError(err)
+		m.Logger.Warnf("unable to persist reading of %q: %s", device, err)
+	}
+}
+
+// records returns all cached readings.
+func (m *MeasureServer) records() ([]store.Record, error) {
+	items, err := m.Cache.Items()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]store.Record, 0, len(items))
+	for _, r := range items {
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// load puts the given readings into the cache with their remaining TTL, skipping readings
+// which would already have expired or which are older than what is already cached.
+func (m *MeasureServer) load(records []store.Record) {
+	ttl := time.Duration(m.ttl.Load())
+	for _, r := range records {
+		remaining := ttl - time.Since(r.Received)
+		if remaining <= 0 {
+			continue
+		}
+		if c, err := m.Cache.Get(r.Device); err == nil && !c.Received.Before(r.Received) {
+			continue
+		}
+		if err := m.Cache.SetWithTTL(r, remaining); err != nil {
+			m.Logger.Warnf("unable to cache reading of %q: %s", r.Device, err)
+		}
+	}
+}
+
+// restore loads the latest reading of every device from the store into the cache.
+func (m *MeasureServer) restore() error {
+	if m.Store == nil {
+		return nil
+	}
+	records, err := m.Store.Latest()
+	if err != nil {
+		return err
+	}
+	m.load(records)
+	return nil
+}
+
+// snapshotLoop periodically writes the cache contents to path.
+func (m *MeasureServer) snapshotLoop(path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		records, err := m.records()
+		if err != nil {
+			m.Logger.Warnf("unable to read cache for snapshot: %s", err)
+			continue
+		}
+		if err := store.WriteSnapshot(path, records); err != nil {
+			m.Logger.Warnf("unable to write snapshot: %s", err)
+		}
+	}
+}
+
+// compactLoop periodically applies the retention policy to the store.
+func (m *MeasureServer) compactLoop(c store.Compactor, ret store.Retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Compact(ret, time.Now()); err != nil {
+			m.Logger.Warnf("unable to compact store: %s", err)
+		}
+		<-ticker.C
+	}
+}
+
+func newCache(cacheType string, ttl time.Duration) (cache.Cache, error) {
+	switch cacheType {
+	case "memory":
+		return cache.NewMemory(ttl), nil
+	case "redis":
+		return cache.NewRedis(*redisAddr, *redisPassword, *redisDB, *redisPrefix, ttl)
+	default:
+		return nil, fmt.Errorf("unknown cache type %q", cacheType)
+	}
+}
+
+func newStore(storeType, path string, timescale bool) (store.Store, error) {
+	switch storeType {
+	case "":
+		return nil, nil
+	case "sqlite":
+		return store.NewSQLite(path)
+	case "postgres":
+		return store.NewPostgres(path, timescale)
+	case "bolt":
+		return store.NewBolt(path)
+	default:
+		return nil, fmt.Errorf("unknown store type %q", storeType)
+	}
 }
 
 func (m *MeasureServer) wsHandler(ctx *gin.Context) {
@@ -49,6 +329,12 @@ func (m *MeasureServer) wsHandler(ctx *gin.Context) {
 		return
 	}
 	defer c.Close()
+	wsConnections.Inc()
+	defer wsConnections.Dec()
+	wsConnectionsTotal.Inc()
+	if err := m.wsRequestStatus(c, rpcGetStatus, nil); err != nil {
+		m.Logger.Warnf("unable to request status: %s", err)
+	}
 
 	for {
 		_, message, err := c.ReadMessage()
@@ -61,84 +347,448 @@ func (m *MeasureServer) wsHandler(ctx *gin.Context) {
 		var msg data.WSMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
 			m.Logger.Warnf("unmarshal failed: %s", err)
+			wsUnmarshalFailures.Inc()
 			break
 		}
+		// Messages without method are responses to requests sent to the device.
+		method := msg.Method
+		if method == "" {
+			method = "response"
+		}
+		wsMessages.WithLabelValues(method).Inc()
 
-		switch msg.Method {
-		case data.MethodNotifyFullStatus:
-			m.Cache.Set(msg.Src, json.RawMessage(message))
-		default:
-			continue
+		// Every message gets its own trace as connections are long lived.
+		msgCtx, span := tracer.Start(context.Background(), "ws "+method, trace.WithAttributes(
+			attribute.String("device", msg.Src),
+			attribute.String("method", method),
+		), trace.WithLinks(trace.LinkFromContext(ctx.Request.Context())))
+		if !m.authorized(ctx, msg.Src) {
+			m.Logger.Warnf("dropping message of unauthorized device %q", msg.Src)
+		} else if msg.Method == "" {
+			m.wsResponse(msgCtx, c, message)
+		} else {
+			m.notify(msgCtx, msg, message)
+		}
+		span.End()
+	}
+}
+
+// notify handles an RPC notification sent by a device via websocket or MQTT.
+func (m *MeasureServer) notify(ctx context.Context, msg data.WSMessage, payload []byte) {
+	msg.Src = m.ids.canonical(msg.Src)
+	if msg.Src != "" {
+		m.seen.touch(msg.Src, time.Now())
+	}
+	switch msg.Method {
+	case data.MethodNotifyFullStatus:
+		m.record(ctx, msg.Src, json.RawMessage(payload))
+	case data.MethodNotifyStatus:
+		// Deltas are applied onto the cached full status. Without one, the delta is all we know.
+		// The cached status is already calibrated, so only the delta is.
+		payload = m.calibrate(msg.Src, payload)
+		if prev, err := m.Cache.Get(msg.Src); err == nil {
+			var cached data.WSMessage
+			if json.Unmarshal(prev.Payload, &cached) == nil && cached.Method == data.MethodNotifyFullStatus {
+				merged, err := data.MergeStatus(prev.Payload, payload)
+				if err != nil {
+					m.Logger.Warnf("unable to merge status of %q: %s", msg.Src, err)
+					return
+				}
+				payload = merged
+			}
+		}
+		m.recordCalibrated(ctx, msg.Src, json.RawMessage(payload), time.Now())
+	case data.MethodNotifyEvent:
+		for _, e := range data.Events(payload, time.Now()) {
+			// BLU advertisements are recorded as readings below.
+			if e.Event == data.BLUEvent {
+				continue
+			}
+			if err := m.Events.add(e); err != nil {
+				m.Logger.Warnf("unable to forward event of %q: %s", e.Device, err)
+			}
+		}
+		// Gateways forward readings of BLU sensors which are recorded as devices of their own.
+		for _, r := range data.BLUReadings(payload) {
+			status, err := json.Marshal(data.ReportStatus{
+				Device:      r.Address,
+				Temperature: r.Temperature,
+				Humidity:    r.Humidity,
+				Battery:     r.Battery,
+			})
+			if err != nil {
+				continue
+			}
+			m.Logger.Debugf("BLU reading of %s via %s", r.Address, msg.Src)
+			m.record(ctx, r.Address, json.RawMessage(status))
 		}
 	}
 }
 
+// reportHandler accepts readings as query parameters, e.g. from action URLs. It is deprecated as
+// GET requests must not change state, see reportPostHandler.
 func (m *MeasureServer) reportHandler(ctx *gin.Context) {
 	type queryParameters struct {
-		ID          string `form:"id"`
-		Temperature string `form:"temp"`
-		Humidity    string `form:"hum"`
+		ID          string   `form:"id"`
+		Temperature *float64 `form:"temp"`
+		Fahrenheit  *float64 `form:"temp_f"`
+		Humidity    *float64 `form:"hum"`
 	}
 
 	var parsedQueryParameters queryParameters
 	if err := ctx.ShouldBind(&parsedQueryParameters); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	r := data.ReportStatus{
 		Device:      parsedQueryParameters.ID,
-		Temperature: parsedQueryParameters.Temperature,
+		Temperature: celsius(parsedQueryParameters.Temperature, parsedQueryParameters.Fahrenheit),
 		Humidity:    parsedQueryParameters.Humidity,
 	}
-	if r.Device == "" || (r.Temperature == "" && r.Humidity == "") {
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("not enough parameters set"))
+	if r.Device == "" || (r.Temperature == nil && r.Humidity == nil) {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, errors.New("not enough parameters set"))
+		return
+	}
+	if err := r.Validate(); err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if !m.authorized(ctx, r.Device) {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusForbidden, fmt.Errorf("not authorized to report device %q", r.Device))
 		return
 	}
 	msg, err := json.Marshal(r)
 	if err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+		abortWithError(ctx, http.StatusBadRequest, err)
 		return
 	}
-	m.Cache.Set(r.Device, json.RawMessage(msg))
+	m.record(ctx.Request.Context(), r.Device, json.RawMessage(msg))
+	reportRequests.WithLabelValues("accepted").Inc()
 
+	m.deprecateGET(ctx, r.Device, reportEndpoint)
 	ctx.JSON(http.StatusOK, gin.H{})
 }
 
+// deprecateGET warns once per device about reporting via GET to endpoint and marks the response
+// as deprecated.
+func (m *MeasureServer) deprecateGET(ctx *gin.Context, device, endpoint string) {
+	if _, warned := m.deprecatedGET.LoadOrStore(m.ids.canonical(device), true); !warned {
+		m.Logger.Warnf("device %q reports via GET %s which is deprecated, use POST %s", device, endpoint, reportEndpoint)
+	}
+	ctx.Header("Deprecation", "true")
+	ctx.Header("Cache-Control", "no-store")
+}
+
 func (m *MeasureServer) collectHandler(ctx *gin.Context) {
 	type queryParameters struct {
-		Device string `form:"device"`
+		// Devices selects a single device or, if repeated, several devices.
+		Devices []string `form:"device"`
+		Format  string   `form:"format"`
+		Tags    []string `form:"tag"`
+		Group   string   `form:"group"`
+		// Devices are paged by ID, starting after cursor if set.
+		Prefix string `form:"prefix"`
+		Limit  int    `form:"limit"`
+		Offset int    `form:"offset"`
+		Cursor string `form:"cursor"`
+		// Only devices updated after since are returned, waiting up to wait for one.
+		Since time.Time     `form:"since"`
+		Wait  time.Duration `form:"wait"`
+		// Fields trims the response to the given comma separated values of each device.
+		Fields string `form:"fields"`
+		// Room is a shorthand for the room tag.
+		Room string `form:"room"`
 	}
 
 	var parsedQueryParameters queryParameters
-	if err := ctx.ShouldBind(&parsedQueryParameters); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	// POST requests list the devices in the body, e.g. {"devices": ["kitchen", "bathroom"]}.
+	if ctx.Request.Method == http.MethodPost {
+		var body struct {
+			Devices []string `json:"devices" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		parsedQueryParameters.Devices = append(parsedQueryParameters.Devices, body.Devices...)
+	}
+	if parsedQueryParameters.Limit < 0 || parsedQueryParameters.Offset < 0 {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("limit and offset must not be negative"))
+		return
+	}
+	if parsedQueryParameters.Wait < 0 || parsedQueryParameters.Wait > maxCollectWait {
+		abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("wait must be between 0 and %s", maxCollectWait))
 		return
 	}
+	tags, err := parseTagFilter(parsedQueryParameters.Tags)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if parsedQueryParameters.Room != "" {
+		tags["room"] = parsedQueryParameters.Room
+	}
+	format, err := tabularFormat(ctx, parsedQueryParameters.Format)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	fields := parseFields(parsedQueryParameters.Fields)
 
 	switch {
-	case parsedQueryParameters.Device != "":
-		s, err := m.Cache.Get(parsedQueryParameters.Device)
+	case parsedQueryParameters.Group != "":
+		m.collectGroup(ctx, parsedQueryParameters.Group, unit)
+	case len(parsedQueryParameters.Devices) > 1 || ctx.Request.Method == http.MethodPost:
+		m.collectDevices(ctx, parsedQueryParameters.Devices, format, fields, unit)
+	case len(parsedQueryParameters.Devices) == 1:
+		r, err := m.Cache.Get(m.ids.canonical(parsedQueryParameters.Devices[0]))
+		if errors.Is(err, cache.ErrNotFound) {
+			abortWithError(ctx, http.StatusNotFound, err)
+			return
+		}
 		if err != nil {
-			ctx.AbortWithError(http.StatusNotFound, err)
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		out := outputRecord(r, unit)
+		if format != "" {
+			m.writeTabular(ctx, format, "collect", []store.Record{out})
+			return
+		}
+		info := m.deviceInfo(r.Device, &r, unit, time.Now())
+		if len(fields) > 0 {
+			ctx.JSON(http.StatusOK, gin.H{
+				"device": r.Device,
+				"values": selectFields(info, &out, fields),
+				"unit":   unit,
+			})
 			return
 		}
 		ctx.JSON(http.StatusOK, gin.H{
-			"status": s.(json.RawMessage),
+			"status": out.Payload,
+			"info":   info,
+			"unit":   unit,
 		})
 	default:
+		prefix := strings.ToLower(parsedQueryParameters.Prefix)
+		match := func(device string) bool {
+			return strings.HasPrefix(device, prefix) && m.registry.get(device).matches(tags)
+		}
+		since := parsedQueryParameters.Since
+		if !since.IsZero() && parsedQueryParameters.Wait > 0 {
+			if err := m.waitForUpdate(ctx.Request.Context(), since, parsedQueryParameters.Wait, match); err != nil {
+				abortWithError(ctx, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		items, err := m.Cache.Items()
+		if err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		// Devices seen recently or registered are listed even without a cached reading, except in
+		// tables and when asking for updates.
+		known := map[string]bool{}
+		for device, r := range items {
+			if r.Received.After(since) {
+				known[device] = true
+			} else {
+				delete(items, device)
+			}
+		}
+		if format == "" && since.IsZero() {
+			for device := range m.seen.items() {
+				known[device] = true
+			}
+			for _, device := range m.registry.registered() {
+				known[device] = true
+			}
+		}
+		var devices []string
+		for device := range known {
+			if match(device) {
+				devices = append(devices, device)
+			}
+		}
+		sort.Strings(devices)
+		total := len(devices)
+		page, next := paginate(devices, parsedQueryParameters.Cursor, parsedQueryParameters.Offset, parsedQueryParameters.Limit)
+
+		if format != "" {
+			records := make([]store.Record, 0, len(page))
+			for _, device := range page {
+				records = append(records, outputRecord(items[device], unit))
+			}
+			m.writeTabular(ctx, format, "collect", records)
+			return
+		}
+		now := time.Now()
 		status := map[string]json.RawMessage{}
-		for k, v := range m.Cache.GetItems() {
-			status[k] = v.(json.RawMessage)
+		info := map[string]deviceInfo{}
+		values := map[string]map[string]any{}
+		for _, device := range page {
+			r, ok := items[device]
+			if !ok {
+				info[device] = m.deviceInfo(device, nil, unit, now)
+				if len(fields) > 0 {
+					values[device] = selectFields(info[device], nil, fields)
+				}
+				continue
+			}
+			out := outputRecord(r, unit)
+			status[device] = out.Payload
+			info[device] = m.deviceInfo(device, &r, unit, now)
+			if len(fields) > 0 {
+				values[device] = selectFields(info[device], &out, fields)
+			}
 		}
-		ctx.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"devices": status,
-		})
+			"info":    info,
+			"unit":    unit,
+			"total":   total,
+		}
+		if len(fields) > 0 {
+			resp = gin.H{
+				"devices": values,
+				"unit":    unit,
+				"total":   total,
+			}
+		}
+		if next != "" {
+			resp["next"] = next
+		}
+		if !since.IsZero() {
+			resp["since"] = latestReceived(items, page, since)
+		}
+		ctx.JSON(http.StatusOK, resp)
 	}
 }
 
+// paginate returns the page of the sorted devices after cursor, skipping offset devices and
+// holding at most limit devices if it is positive. If more devices follow, the cursor of the next
+// page is returned as well.
+func paginate(devices []string, cursor string, offset, limit int) ([]string, string) {
+	if cursor != "" {
+		devices = devices[sort.Search(len(devices), func(i int) bool { return devices[i] > cursor }):]
+	}
+	devices = devices[min(offset, len(devices)):]
+	if limit <= 0 || len(devices) <= limit {
+		return devices, ""
+	}
+	return devices[:limit], devices[limit-1]
+}
+
+// deviceInfo describes the cached reading of a device in collect responses.
+type deviceInfo struct {
+	// Name is the friendly name assigned to the device, if any.
+	Name   string            `json:"name,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Groups []string          `json:"groups,omitempty"`
+	// Virtual is set for devices computed from the readings of others.
+	Virtual bool `json:"virtual,omitempty"`
+	// Registered devices are listed before they report and flagged as Silent if they don't.
+	Registered bool `json:"registered,omitempty"`
+	Silent     bool `json:"silent,omitempty"`
+	// ReceivedAt is when the reading was received, DeviceTime when it was sent according to the
+	// clock of the device. Consumers can compare them to detect stale data or drifting clocks.
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+	DeviceTime *time.Time `json:"device_time,omitempty"`
+	// LastSeen is the last contact with the device, which is also tracked for devices whose
+	// reading already expired from the cache.
+	LastSeen time.Time `json:"last_seen"`
+	Stale    bool      `json:"stale"`
+	// Battery, ExternalPower and RSSI show which sensors need new batteries or a better spot.
+	Battery       *float64 `json:"battery,omitempty"`
+	ExternalPower *bool    `json:"external_power,omitempty"`
+	RSSI          *float64 `json:"rssi,omitempty"`
+	// Model, Firmware and UpdateAvailable show which sensors need firmware updates.
+	Model             string `json:"model,omitempty"`
+	Firmware          string `json:"firmware,omitempty"`
+	UpdateAvailable   *bool  `json:"update_available,omitempty"`
+	AvailableFirmware string `json:"available_firmware,omitempty"`
+	// Channels lists all probes of devices with multiple sensors.
+	Channels []data.Channel `json:"channels,omitempty"`
+	// DewPoint and HeatIndex are computed from temperature and humidity.
+	DewPoint  *float64 `json:"dew_point,omitempty"`
+	HeatIndex *float64 `json:"heat_index,omitempty"`
+	// AbsoluteHumidity in g/m³ and VPD in kPa are computed for greenhouses and drying rooms.
+	AbsoluteHumidity *float64 `json:"absolute_humidity,omitempty"`
+	VPD              *float64 `json:"vpd,omitempty"`
+	// Values holds the metrics of other sensor types such as pressure, illuminance or CO2.
+	Values map[string]float64 `json:"values,omitempty"`
+	// Rolling holds the minimum, maximum and average of every metric by window, e.g. 24h.
+	Rolling map[string]map[string]windowStats `json:"rolling,omitempty"`
+	// Trends shows whether metrics are rising, falling or steady.
+	Trends map[string]trend `json:"trends,omitempty"`
+	// Comfort classifies the reading by the comfort rules, e.g. to warn of mold risk.
+	Comfort *data.Comfort `json:"comfort,omitempty"`
+	// Implausible lists the metrics outside of the plausible ranges, which are only recorded if
+	// they are flagged.
+	Implausible []string `json:"implausible,omitempty"`
+}
+
+// deviceInfo returns the info of a device based on its cached reading if there is one.
+// Temperatures are returned in unit.
+func (m *MeasureServer) deviceInfo(device string, r *store.Record, unit string, now time.Time) deviceInfo {
+	meta := m.registry.get(device)
+	_, virtual := m.virtual[device]
+	info := deviceInfo{Name: meta.Name, Tags: meta.Tags, Groups: meta.Groups, Virtual: virtual}
+	if r != nil {
+		info.ReceivedAt = &r.Received
+		info.DeviceTime = r.Reading.DeviceTime
+		info.Battery = r.Reading.Battery
+		info.ExternalPower = r.Reading.ExternalPower
+		info.RSSI = r.Reading.RSSI
+		info.Values = r.Reading.Values
+		info.Model = r.Reading.Model
+		info.Firmware = r.Reading.Firmware
+		info.UpdateAvailable = r.Reading.UpdateAvailable
+		info.AvailableFirmware = r.Reading.AvailableFirmware
+		converted := r.Reading.InUnit(unit)
+		info.Channels = converted.Channels
+		info.DewPoint = converted.DewPoint
+		info.HeatIndex = converted.HeatIndex
+		info.AbsoluteHumidity = r.Reading.AbsoluteHumidity
+		info.VPD = r.Reading.VPD
+		info.Implausible = r.Reading.Implausible(m.plausibility.bounds)
+		info.Comfort = data.Classify(r.Reading.Metrics(), m.comfort.rules)
+		// Readings restored on startup predate the tracked contacts.
+		info.LastSeen = r.Received
+	}
+	if t, ok := m.seen.get(device); ok && t.After(info.LastSeen) {
+		info.LastSeen = t
+	}
+	info.Stale = now.Sub(info.LastSeen) > *staleAfter
+	info.Registered = meta.Registered
+	info.Silent = silent(meta, info.LastSeen, now)
+	info.Rolling = m.rolling.stats(device, now, unit)
+	info.Trends = m.trends(device, now, unit)
+	return info
+}
+
 func main() {
-	flag.Parse()
+	// The import subcommand shares all flags with the server: measure import [flags] <file.csv>...
+	importMode := len(os.Args) > 1 && os.Args[1] == "import"
+	if importMode {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 
 	// Set up logging
 	log := logging.NewLogger("MAIN")
@@ -149,24 +799,384 @@ func main() {
 	logging.SetMinLogLevel(lvl)
 	defer log.Shutdown()
 
-	cache := ttlcache.NewCache()
-	cache.SetTTL(time.Duration(*cacheTTL))
+	if importMode {
+		if err := runImport(flag.Args()); err != nil {
+			log.Fatalf("Import failed: %s", err)
+		}
+		return
+	}
+
+	if _, err := parseUnit(*temperatureUnit); err != nil {
+		log.Fatalf("Invalid -temperatureUnit: %s", err)
+	}
+	if *historyDepth < 0 {
+		log.Fatalf("Invalid -historyDepth %d: must not be negative", *historyDepth)
+	}
+
+	c, err := newCache(*cacheType, *cacheTTL)
+	if err != nil {
+		log.Fatalf("Unable to set up cache: %s", err)
+	}
+	defer c.Close()
+
+	st, err := newStore(*storeType, *storePath, *storeTimescale)
+	if err != nil {
+		log.Fatalf("Unable to set up store: %s", err)
+	}
+	if st != nil {
+		defer st.Close()
+	}
+
+	var wal *store.WAL
+	if *walPath != "" {
+		if wal, err = store.NewWAL(*walPath, *walMaxSize, *walMaxFiles); err != nil {
+			log.Fatalf("Unable to set up write-ahead log: %s", err)
+		}
+		defer wal.Close()
+	}
+
+	if *sinkBatchSize <= 0 {
+		log.Fatalf("Invalid -sinkBatchSize %d: must be positive", *sinkBatchSize)
+	}
+	if *sinkFlushInterval <= 0 {
+		log.Fatalf("Invalid -sinkFlushInterval %s: must be positive", *sinkFlushInterval)
+	}
+	sinks, err := newSinks()
+	if err != nil {
+		log.Fatalf("Unable to set up sinks: %s", err)
+	}
+	for _, s := range sinks {
+		defer s.Close()
+	}
+
+	if *otlpEndpoint != "" {
+		shutdown, err := setupTracing(*otlpEndpoint, *otlpInsecure)
+		if err != nil {
+			log.Fatalf("Unable to set up tracing: %s", err)
+		}
+		defer shutdown(context.Background())
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 	router.SetFuncMap(template.FuncMap{})
+	router.Use(instrument, requestID, otelgin.Middleware(serviceName))
+	if *compressResponses {
+		router.Use(compress)
+	}
+
+	cals, err := parseCalibrations(calibrations)
+	if err != nil {
+		log.Fatalf("Invalid calibration: %s", err)
+	}
+
+	plaus, err := newPlausibility(*plausibleTemperature, *plausibleHumidity, *implausiblePolicy)
+	if err != nil {
+		log.Fatalf("Invalid plausibility check: %s", err)
+	}
+
+	thresholds, err := parseThresholds(*trendThresholds)
+	if err != nil {
+		log.Fatalf("Invalid trend threshold: %s", err)
+	}
+
+	ids, err := newDeviceIDs(deviceAliases)
+	if err != nil {
+		log.Fatalf("Invalid device alias: %s", err)
+	}
+
+	spikeLimits, err := parseThresholds(*spikeThresholds)
+	if err != nil {
+		log.Fatalf("Invalid spike threshold: %s", err)
+	}
+
+	virtual, err := parseVirtualDevices(virtualDevices)
+	if err != nil {
+		log.Fatalf("Invalid virtual device: %s", err)
+	}
+
+	rules, err := parseComfortRules(comfortRules)
+	if err != nil {
+		log.Fatalf("Invalid comfort rule: %s", err)
+	}
+
+	reg, err := loadRegistry(*devicesFile)
+	if err != nil {
+		log.Fatalf("Unable to load devices: %s", err)
+	}
+	for _, device := range reg.registered() {
+		for _, alias := range reg.get(device).Aliases {
+			if err := ids.alias(alias, device); err != nil {
+				log.Fatalf("Invalid alias of registered device: %s", err)
+			}
+		}
+	}
+
+	basic, err := newBasicCredentials(*basicAuthUser, *basicAuthPassword)
+	if err != nil {
+		log.Fatalf("Invalid basic auth: %s", err)
+	}
+
+	jwtv, err := newJWTValidator(*jwtSecret, *jwksURL, *jwtIssuer, *jwtAudience, *jwtScopeClaim)
+	if err != nil {
+		log.Fatalf("Unable to set up JWT validation: %s", err)
+	}
+
+	var hook *sink.EventHook
+	if *eventWebhook != "" {
+		hook = sink.NewEventHook(*eventWebhook, logging.NewLogger("EVNT"))
+		defer hook.Close()
+	}
 
 	srv := MeasureServer{
-		Cache: cache,
+		Cache:        c,
+		Store:        st,
+		WAL:          wal,
+		History:      store.NewHistory(*historyDepth),
+		Sinks:        sinks,
+		dedup:        newDeduper(*dedupWindow),
+		hub:          newHub(),
+		jwt:          jwtv,
+		basicAuth:    basic,
+		seen:         newLastSeen(),
+		rolling:      newRolling(),
+		comfort:      newComfort(rules),
+		spikes:       newSpikeFilter(*spikeWindow, spikeLimits),
+		virtual:      virtual,
+		trendLimits:  thresholds,
+		Events:       newEventLog(*eventsDepth, hook),
+		calibrations: cals,
+		plausibility: plaus,
+		registry:     reg,
+		ids:          ids,
 		Server: &http.Server{
 			Addr:    fmt.Sprintf(":%d", *port),
 			Handler: router, // use `http.DefaultServeMux`
 		},
 		Logger: logging.NewLogger("SERV"),
 	}
-	router.GET(wsEndpoint, srv.wsHandler)
-	router.GET(collectEndpoint, srv.collectHandler)
-	router.GET(reportEndpoint, srv.reportHandler)
+	srv.ttl.Store(int64(*cacheTTL))
+	if err := srv.restore(); err != nil {
+		log.Fatalf("Unable to restore readings from store: %s", err)
+	}
+	if *retentionRaw > 0 {
+		c, ok := st.(store.Compactor)
+		if !ok {
+			log.Fatalf("Store %q does not support retention", *storeType)
+		}
+		ret := store.Retention{
+			Raw:         *retentionRaw,
+			Window:      *retentionWindow,
+			Downsampled: *retentionDownsampled,
+		}
+		go srv.compactLoop(c, ret, *compactionInterval)
+	}
+	if wal != nil && *walReplay {
+		var records []store.Record
+		if err := store.ReplayWAL(*walPath, *walMaxFiles, func(r store.Record) {
+			records = append(records, r)
+			srv.History.Add(r)
+			srv.rolling.add(r.Device, r.Received, r.Reading.Metrics())
+		}); err != nil {
+			log.Fatalf("Unable to replay write-ahead log: %s", err)
+		}
+		srv.load(records)
+	}
+	if *snapshotPath != "" {
+		records, err := store.ReadSnapshot(*snapshotPath)
+		if err != nil {
+			log.Fatalf("Unable to restore snapshot: %s", err)
+		}
+		srv.load(records)
+		go srv.snapshotLoop(*snapshotPath, *snapshotInterval)
+	}
+
+	if *archiveEndpoint != "" {
+		if _, ok := st.(store.Deleter); !ok {
+			log.Fatalf("Store %q does not support archival", *storeType)
+		}
+		if srv.Archive, err = archive.New(*archiveEndpoint, *archiveAccessKey, *archiveSecretKey, *archiveBucket, *archivePrefix, *archiveTLS); err != nil {
+			log.Fatalf("Unable to set up archive: %s", err)
+		}
+		go srv.archiveLoop(*archiveAfter, *archiveInterval)
+	}
+	tagLabels, err := parseTagLabels(*prometheusTags)
+	if err != nil {
+		log.Fatalf("Invalid -prometheusTags: %s", err)
+	}
+	prometheus.MustRegister(deviceCollector{m: &srv, labels: tagLabels}, srv.cacheSize())
+	if *statsdAddr != "" {
+		s, err := sink.NewStatsD(*statsdAddr, *statsdPrefix, *statsdTags)
+		if err != nil {
+			log.Fatalf("Unable to set up StatsD: %s", err)
+		}
+		defer s.Close()
+		srv.Sinks = append(srv.Sinks, s)
+		go srv.statsdCounterLoop(s, *sinkFlushInterval)
+	}
+	readAuth, writeAuth := srv.requireScope(scopeRead), srv.requireScope(scopeWrite)
+
+	var p *poller
+	if len(pollHosts) > 0 || (*discoveryEnabled && *discoveryPoll) {
+		if *pollInterval <= 0 {
+			log.Fatalf("Invalid -pollInterval %s: must be positive", *pollInterval)
+		}
+		p = newPoller(&srv, *pollInterval)
+		for _, host := range pollHosts {
+			p.add(host)
+		}
+		go p.run()
+	}
+	if *discoveryEnabled {
+		if *discoveryInterval <= 0 {
+			log.Fatalf("Invalid -discoveryInterval %s: must be positive", *discoveryInterval)
+		}
+		d := newDiscovery(&srv, p, *discoveryInterval)
+		go d.run()
+		router.GET(discoveryEndpoint, readAuth, d.handler)
+	}
+	if *grpcPort != 0 {
+		if err := srv.serveGRPC(*grpcPort, *tlsCert, *tlsKey); err != nil {
+			log.Fatalf("Unable to start gRPC API: %s", err)
+		}
+	}
+	if *udpAddr != "" {
+		if err := srv.listenUDP(*udpAddr); err != nil {
+			log.Fatalf("Unable to listen for UDP readings: %s", err)
+		}
+	}
+	if *coiotEnabled {
+		if err := srv.listenCoIoT(*coiotAddr); err != nil {
+			log.Fatalf("Unable to listen for CoIoT: %s", err)
+		}
+	}
+	subscriptions := map[string]mqtt.MessageHandler{}
+	for _, topic := range mqttSubscribe {
+		subscriptions[topic] = srv.mqttHandler
+	}
+	for _, node := range esphomeNodes {
+		subscriptions[node+"/sensor/+/state"] = srv.esphomeHandler(node)
+	}
+	if *zigbee2mqttTopic != "" {
+		subscriptions[*zigbee2mqttTopic+"/#"] = srv.zigbeeHandler(*zigbee2mqttTopic)
+	}
+	if *mqttBroker != "" && len(subscriptions) > 0 {
+		client, err := srv.subscribeMQTT(mqttConfig(), subscriptions)
+		if err != nil {
+			log.Fatalf("Unable to subscribe to MQTT: %s", err)
+		}
+		defer client.Disconnect(uint(time.Second / time.Millisecond))
+	}
+	if *otlpMetricsEndpoint != "" {
+		shutdown, err := srv.setupMetrics(*otlpMetricsEndpoint, *otlpInsecure, *otlpMetricsInterval)
+		if err != nil {
+			log.Fatalf("Unable to set up OTLP metrics: %s", err)
+		}
+		defer shutdown(context.Background())
+	}
+	if *exportDir != "" {
+		go srv.exportLoop(*exportDir, *exportInterval)
+	}
+
+	router.GET(wsEndpoint, writeAuth, srv.wsHandler)
+	router.GET(subscribeEndpoint, readAuth, srv.subscribeHandler)
+	router.GET(collectEndpoint, readAuth, srv.collectHandler)
+	router.POST(collectEndpoint, readAuth, srv.collectHandler)
+	if *reportGET {
+		router.GET(reportEndpoint, writeAuth, srv.reportHandler)
+		router.GET(gen1Endpoint, writeAuth, srv.gen1Handler)
+	}
+	router.POST(reportEndpoint, writeAuth, srv.reportPostHandler)
+	router.POST(reportEndpoint+"/batch", writeAuth, srv.reportBatchHandler)
+	router.GET(eventsEndpoint, readAuth, srv.eventsHandler)
+	ttnMetrics, err := parseTTNMapping(*ttnMapping)
+	if err != nil {
+		log.Fatalf("Invalid -ttnMapping: %s", err)
+	}
+	if *ttnToken != "" {
+		router.POST(ttnEndpoint, bearerAuth(*ttnToken), srv.ttnHandler(ttnMetrics))
+	} else {
+		router.POST(ttnEndpoint, writeAuth, srv.ttnHandler(ttnMetrics))
+	}
+	if len(remoteWriteReceive) > 0 {
+		series, err := parseRemoteWriteReceive(remoteWriteReceive)
+		if err != nil {
+			log.Fatalf("Invalid -remoteWriteReceive: %s", err)
+		}
+		router.POST(receiveEndpoint, writeAuth, srv.remoteWriteHandler(series, strings.Split(*remoteWriteDeviceLabels, ",")))
+	}
+	if *ingestProfiles != "" {
+		profiles, err := loadIngestProfiles(*ingestProfiles)
+		if err != nil {
+			log.Fatalf("Unable to load ingest profiles: %s", err)
+		}
+		router.POST(ingestEndpoint, writeAuth, srv.ingestHandler(profiles))
+	}
+	router.GET(historyEndpoint, readAuth, srv.historyHandler)
+	router.GET(aggregateEndpoint, readAuth, srv.aggregateHandler)
+	router.GET(diffEndpoint, readAuth, srv.diffHandler)
+	router.GET(streamEndpoint, readAuth, srv.streamHandler)
+	router.GET(exportEndpoint, readAuth, srv.exportHandler)
+	router.GET(metricsEndpoint, readAuth, gin.WrapH(promhttp.Handler()))
+	schema, err := srv.newGraphQLSchema()
+	if err != nil {
+		log.Fatalf("Unable to parse GraphQL schema: %s", err)
+	}
+	router.POST(graphqlEndpoint, readAuth, graphqlHandler(schema))
+	router.GET(openAPIEndpoint, openAPIHandler)
+	if *swaggerUI {
+		router.GET(docsEndpoint, swaggerHandler)
+	}
+	router.POST(writeEndpoint, writeAuth, srv.writeHandler)
+
+	grafana := router.Group(grafanaEndpoint, readAuth)
+	grafana.GET("/", srv.grafanaTestHandler)
+	grafana.POST("/search", srv.grafanaSearchHandler)
+	grafana.POST("/query", srv.grafanaQueryHandler)
+	grafana.POST("/annotations", srv.grafanaAnnotationsHandler)
+
+	router.GET(devicesEndpoint, readAuth, srv.devicesHandler)
+	router.GET(devicesEndpoint+"/:id", readAuth, srv.deviceHandler)
+	router.GET(devicesEndpoint+"/:id/readings", readAuth, srv.readingsHandler)
+	devices := router.Group(devicesEndpoint, srv.adminAuth(*adminToken))
+	devices.POST("", srv.registerDeviceHandler)
+	devices.PATCH("/:id", srv.patchDeviceHandler)
+	devices.DELETE("/:id", srv.deleteDeviceHandler)
+	devices.PUT("/:id/name", srv.nameHandler)
+	devices.DELETE("/:id/name", srv.deleteNameHandler)
+	devices.POST("/:id/token", srv.issueTokenHandler)
+	devices.DELETE("/:id/token", srv.revokeTokenHandler)
+
+	admin := router.Group(adminEndpoint, srv.adminAuth(*adminToken))
+	admin.GET("/backup", srv.backupHandler)
+	admin.POST("/restore", srv.restoreHandler)
+	admin.GET("/cache", srv.cacheHandler)
+	admin.DELETE("/cache", srv.flushCacheHandler)
+	admin.DELETE("/cache/:id", srv.purgeCacheHandler)
+	admin.PUT("/cache/ttl", srv.cacheTTLHandler)
+
+	// v2 serves the JSON endpoints of v1 with a common envelope. v1 stays as is as it is
+	// configured as action URL on devices.
+	v2 := router.Group(apiV2Endpoint, envelope)
+	v2.GET("/collect", readAuth, srv.collectHandler)
+	v2.POST("/collect", readAuth, srv.collectHandler)
+	v2.POST("/report", writeAuth, srv.reportPostHandler)
+	v2.POST("/report/batch", writeAuth, srv.reportBatchHandler)
+	v2.GET("/history", readAuth, srv.historyHandler)
+	v2.GET("/aggregate", readAuth, srv.aggregateHandler)
+	v2.GET("/diff", readAuth, srv.diffHandler)
+	v2.GET("/events", readAuth, srv.eventsHandler)
+	v2.GET("/devices", readAuth, srv.devicesHandler)
+	v2.GET("/devices/:id", readAuth, srv.deviceHandler)
+	v2.GET("/devices/:id/readings", readAuth, srv.readingsHandler)
+	v2Devices := v2.Group("/devices", srv.adminAuth(*adminToken))
+	v2Devices.POST("", srv.registerDeviceHandler)
+	v2Devices.PATCH("/:id", srv.patchDeviceHandler)
+	v2Devices.DELETE("/:id", srv.deleteDeviceHandler)
+	v2Devices.PUT("/:id/name", srv.nameHandler)
+	v2Devices.DELETE("/:id/name", srv.deleteNameHandler)
+	v2Devices.POST("/:id/token", srv.issueTokenHandler)
+	v2Devices.DELETE("/:id/token", srv.revokeTokenHandler)
 
 	if *tlsCert != "" && *tlsKey != "" {
 		router.RunTLS(fmt.Sprintf(":%d", *port), *tlsCert, *tlsKey)
@@ -0,0 +1,4 @@
+// Package measurepb contains the gRPC API of measure generated from measure.proto.
+package measurepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative measure.proto
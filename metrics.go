@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/sink"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Operational metrics of the service itself.
+var (
+	wsConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "measure_websocket_connections",
+		Help: "Number of currently open device websocket connections.",
+	})
+	wsConnectionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "measure_websocket_connections_total",
+		Help: "Number of device websocket connections accepted.",
+	})
+	wsMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "measure_websocket_messages_total",
+		Help: "Number of websocket messages received by method.",
+	}, []string{"method"})
+	wsUnmarshalFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "measure_websocket_unmarshal_failures_total",
+		Help: "Number of websocket messages which could not be parsed.",
+	})
+	mqttMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "measure_mqtt_messages_total",
+		Help: "Number of MQTT messages received by method.",
+	}, []string{"method"})
+	reportRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "measure_report_requests_total",
+		Help: "Number of requests to the report endpoint by result.",
+	}, []string{"result"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "measure_http_request_duration_seconds",
+		Help:    "Latency of HTTP handlers.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "method", "code"})
+)
+
+// instrument records the latency of every handled request. Websocket connections and streams
+// are excluded as they are long lived.
+func instrument(ctx *gin.Context) {
+	switch ctx.FullPath() {
+	case wsEndpoint, subscribeEndpoint, streamEndpoint:
+		ctx.Next()
+		return
+	}
+	start := time.Now()
+	ctx.Next()
+	handler := ctx.FullPath()
+	if handler == "" {
+		handler = "unknown"
+	}
+	requestDuration.WithLabelValues(handler, ctx.Request.Method, strconv.Itoa(ctx.Writer.Status())).Observe(time.Since(start).Seconds())
+}
+
+// cacheSize returns a gauge reporting the number of cached devices.
+func (m *MeasureServer) cacheSize() prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "measure_cache_entries",
+		Help: "Number of devices currently cached.",
+	}, func() float64 {
+		items, err := m.Cache.Items()
+		if err != nil {
+			return 0
+		}
+		return float64(len(items))
+	})
+}
+
+var prometheusTags = flag.String("prometheusTags", "room", "Comma separated device tags exported as labels of the device gauges, e.g. room,floor. Devices without a tag get an empty label.")
+
+// tagLabel matches the tag keys which are valid Prometheus label names.
+var tagLabel = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseTagLabels parses the -prometheusTags flag.
+func parseTagLabels(s string) ([]string, error) {
+	var labels []string
+	for _, l := range strings.Split(s, ",") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+			continue
+		case l == "device" || !tagLabel.MatchString(l):
+			return nil, fmt.Errorf("tag %q can't be used as label", l)
+		case !slices.Contains(labels, l):
+			labels = append(labels, l)
+		}
+	}
+	return labels, nil
+}
+
+var metricHelp = map[string]string{
+	data.MetricTemperature: "Temperature reported by a device in degrees Celsius.",
+	data.MetricHumidity:    "Relative humidity reported by a device in percent.",
+	data.MetricBattery:     "Battery level reported by a device in percent.",
+	data.MetricDewPoint:    "Dew point computed from the temperature and humidity of a device in degrees Celsius.",
+	data.MetricHeatIndex:   "Heat index computed from the temperature and humidity of a device in degrees Celsius.",
+
+	data.MetricAbsoluteHumidity: "Absolute humidity computed from the temperature and humidity of a device in g/m³.",
+	data.MetricVPD:              "Vapor pressure deficit computed from the temperature and humidity of a device in kPa.",
+
+	data.MetricPressure:    "Air pressure reported by a device in hPa.",
+	data.MetricIlluminance: "Illuminance reported by a device in lux.",
+	data.MetricCO2:         "CO2 concentration reported by a device in ppm.",
+}
+
+// deviceCollector exports the latest cached reading of every device as Prometheus gauges,
+// labeled with the device and its tags listed in labels.
+type deviceCollector struct {
+	m      *MeasureServer
+	labels []string
+}
+
+func (c deviceCollector) desc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, append([]string{"device"}, c.labels...), nil)
+}
+
+func (c deviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	// The exported metrics depend on what devices report, so the collector is unchecked.
+}
+
+func (c deviceCollector) Collect(ch chan<- prometheus.Metric) {
+	items, err := c.m.Cache.Items()
+	if err != nil {
+		c.m.Logger.Warnf("unable to read cache for metrics: %s", err)
+		return
+	}
+	lastReport := c.desc("measure_last_report_timestamp_seconds", "Unix time of the last reading received from a device.")
+	comfortLevel := c.desc("measure_comfort_level", "Comfort level of the latest reading of a device by the comfort rules, 0 ok, 1 warning, 2 critical.")
+	descs := map[string]*prometheus.Desc{}
+	for device, r := range items {
+		tags := c.m.registry.get(device).Tags
+		values := []string{device}
+		for _, l := range c.labels {
+			values = append(values, tags[l])
+		}
+		ch <- prometheus.MustNewConstMetric(lastReport, prometheus.GaugeValue, float64(r.Received.UnixMilli())/1000, values...)
+		if cls := data.Classify(r.Reading.Metrics(), c.m.comfort.rules); cls != nil {
+			ch <- prometheus.MustNewConstMetric(comfortLevel, prometheus.GaugeValue, float64(data.ComfortSeverity(cls.Level)), values...)
+		}
+		for metric, value := range r.Reading.Metrics() {
+			desc, ok := descs[metric]
+			if !ok {
+				help, ok := metricHelp[metric]
+				if !ok {
+					help = "Value of " + metric + " reported by a device."
+				}
+				desc = c.desc(sink.PrometheusName(metric), help)
+				descs[metric] = desc
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, values...)
+		}
+	}
+}
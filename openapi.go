@@ -0,0 +1,27 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var swaggerUI = flag.Bool("swaggerUI", false, "Serve Swagger UI rendering the OpenAPI document at /measure/v1/docs. The UI assets are loaded from a CDN by the browser.")
+
+// openAPI describes all endpoints. It has to be kept in sync with the handlers by hand.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+//go:embed swagger.html
+var swaggerPage []byte
+
+func openAPIHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, gin.MIMEJSON, openAPI)
+}
+
+func swaggerHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", swaggerPage)
+}
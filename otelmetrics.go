@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// otelUnits holds the UCUM units of the device gauges pushed via OTLP. Only these metrics are
+// pushed as OpenTelemetry instruments have to be created upfront.
+var otelUnits = map[string]string{
+	data.MetricTemperature: "Cel",
+	data.MetricHumidity:    "%",
+	data.MetricBattery:     "%",
+}
+
+// setupMetrics periodically pushes the latest cached reading of every device as gauges via
+// OTLP/gRPC to endpoint (host:port). The returned function flushes and stops the export.
+func (m *MeasureServer) setupMetrics(endpoint string, insecure bool, interval time.Duration) (func(context.Context) error, error) {
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	exporter, err := otlpmetricgrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+		sdkmetric.WithResource(res),
+	)
+	meter := mp.Meter("github.com/finfinack/measure")
+
+	lastReport, err := meter.Float64ObservableGauge("measure.last_report",
+		metric.WithDescription("Unix time of the last reading received from a device."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	gauges := map[string]metric.Float64ObservableGauge{}
+	observables := []metric.Observable{lastReport}
+	for name, unit := range otelUnits {
+		g, err := meter.Float64ObservableGauge("measure."+name,
+			metric.WithDescription(metricHelp[name]),
+			metric.WithUnit(unit))
+		if err != nil {
+			return nil, err
+		}
+		gauges[name] = g
+		observables = append(observables, g)
+	}
+
+	if _, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		items, err := m.Cache.Items()
+		if err != nil {
+			return err
+		}
+		for device, r := range items {
+			attrs := metric.WithAttributes(attribute.String("device", device))
+			o.ObserveFloat64(lastReport, float64(r.Received.UnixMilli())/1000, attrs)
+			for name, value := range r.Reading.Metrics() {
+				if g, ok := gauges[name]; ok {
+					o.ObserveFloat64(g, value, attrs)
+				}
+			}
+		}
+		return nil
+	}, observables...); err != nil {
+		return nil, err
+	}
+	return mp.Shutdown, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Policies for readings outside of the plausible ranges.
+const (
+	policyDrop  = "drop"
+	policyClamp = "clamp"
+	policyFlag  = "flag"
+)
+
+var (
+	plausibleTemperature = flag.String("plausibleTemperature", "-50:100", "Range <min>:<max> of plausible temperatures in Celsius.")
+	plausibleHumidity    = flag.String("plausibleHumidity", "0:100", "Range <min>:<max> of plausible relative humidities in percent.")
+	implausiblePolicy    = flag.String("implausiblePolicy", policyDrop, "Handling of readings outside of the plausible ranges: drop them, clamp the values or flag them as implausible in collect.")
+
+	implausibleReadings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "measure_implausible_readings_total",
+		Help: "Number of readings with values outside of the plausible ranges by metric.",
+	}, []string{"metric"})
+)
+
+// plausibility guards against glitching sensors.
+type plausibility struct {
+	bounds map[string]data.Bounds
+	policy string
+}
+
+func newPlausibility(temperature, humidity, policy string) (*plausibility, error) {
+	switch policy {
+	case policyDrop, policyClamp, policyFlag:
+	default:
+		return nil, fmt.Errorf("unsupported policy %q", policy)
+	}
+	p := &plausibility{bounds: map[string]data.Bounds{}, policy: policy}
+	for metric, v := range map[string]string{data.MetricTemperature: temperature, data.MetricHumidity: humidity} {
+		b, err := data.ParseBounds(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", metric, err)
+		}
+		p.bounds[metric] = b
+	}
+	return p, nil
+}
+
+// checkPlausible applies the policy to a reading. It returns the payload to record or false if the
+// reading is dropped.
+func (m *MeasureServer) checkPlausible(r store.Record) (json.RawMessage, bool) {
+	p := m.plausibility
+	implausible := r.Reading.Implausible(p.bounds)
+	if len(implausible) == 0 {
+		return r.Payload, true
+	}
+	for _, metric := range implausible {
+		implausibleReadings.WithLabelValues(metric).Inc()
+	}
+	switch p.policy {
+	case policyDrop:
+		m.Logger.Infof("dropping implausible %v of %q", implausible, r.Device)
+		return nil, false
+	case policyClamp:
+		clamped, err := data.MapValues(r.Payload, func(metric string, v float64) float64 {
+			return p.bounds[metric].Clamp(v)
+		})
+		if err != nil {
+			m.Logger.Warnf("unable to clamp reading of %q: %s", r.Device, err)
+			return nil, false
+		}
+		return clamped, true
+	}
+	return r.Payload, true
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const pollTimeout = 10 * time.Second
+
+var (
+	pollInterval = flag.Duration("pollInterval", time.Minute, "Interval in which devices given with -poll are polled.")
+
+	pollHosts stringList
+)
+
+func init() {
+	flag.Var(&pollHosts, "poll", "Host (IP or name) of a Shelly Gen2 device to poll via HTTP RPC. Can be repeated.")
+}
+
+// poller periodically fetches the status of Shelly Gen2 devices via HTTP RPC. It suits mains
+// powered devices which are always reachable, battery powered ones sleep most of the time.
+type poller struct {
+	m        *MeasureServer
+	client   *http.Client
+	interval time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]string // host (IP or name) -> device ID, empty until known
+}
+
+func newPoller(m *MeasureServer, interval time.Duration) *poller {
+	return &poller{
+		m:        m,
+		client:   &http.Client{Timeout: pollTimeout},
+		interval: interval,
+		hosts:    map[string]string{},
+	}
+}
+
+// add starts polling host. Adding a host twice has no effect.
+func (p *poller) add(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.hosts[host]; !ok {
+		p.hosts[host] = ""
+	}
+}
+
+// remove stops polling host.
+func (p *poller) remove(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.hosts, host)
+}
+
+func (p *poller) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.pollAll()
+		<-ticker.C
+	}
+}
+
+func (p *poller) pollAll() {
+	p.mu.Lock()
+	hosts := make([]string, 0, len(p.hosts))
+	for host := range p.hosts {
+		hosts = append(hosts, host)
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.poll(host); err != nil {
+				p.m.Logger.Warnf("unable to poll %q: %s", host, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *poller) poll(host string) error {
+	ctx, span := tracer.Start(context.Background(), "poll", trace.WithAttributes(attribute.String("host", host)))
+	defer span.End()
+
+	p.mu.Lock()
+	device := p.hosts[host]
+	p.mu.Unlock()
+	if device == "" {
+		var info struct {
+			ID string `json:"id"`
+		}
+		if err := p.rpc(ctx, host, "Shelly.GetDeviceInfo", &info); err != nil {
+			return err
+		}
+		if info.ID == "" {
+			return fmt.Errorf("device did not report its ID")
+		}
+		device = info.ID
+		p.mu.Lock()
+		p.hosts[host] = device
+		p.mu.Unlock()
+	}
+
+	var status map[string]json.RawMessage
+	if err := p.rpc(ctx, host, "Shelly.GetStatus", &status); err != nil {
+		return err
+	}
+	msg, err := fullStatus(device, status)
+	if err != nil {
+		return err
+	}
+	p.m.record(ctx, device, msg)
+	return nil
+}
+
+// fullStatus wraps the result of Shelly.GetStatus into a NotifyFullStatus message as devices send
+// the same status with it.
+func fullStatus(device string, status map[string]json.RawMessage) (json.RawMessage, error) {
+	var sys struct {
+		UnixTime *float64 `json:"unixtime"`
+	}
+	if raw, ok := status["sys"]; ok && json.Unmarshal(raw, &sys) == nil && sys.UnixTime != nil {
+		status["ts"], _ = json.Marshal(*sys.UnixTime)
+	}
+	return json.Marshal(struct {
+		data.WSMessage
+		Params map[string]json.RawMessage `json:"params"`
+	}{
+		WSMessage: data.WSMessage{Src: device, Dst: serviceName, Method: data.MethodNotifyFullStatus},
+		Params:    status,
+	})
+}
+
+func (p *poller) rpc(ctx context.Context, host, method string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/rpc/%s", host, method), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", method, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+// silent returns whether a registered device missed its reports: it never reported or not
+// within two of its expected intervals, or within staleAfter if it has none.
+func silent(meta deviceMeta, lastSeen, now time.Time) bool {
+	if !meta.Registered {
+		return false
+	}
+	if lastSeen.IsZero() {
+		return true
+	}
+	after := *staleAfter
+	if interval, err := time.ParseDuration(meta.Interval); err == nil && interval > 0 {
+		after = 2 * interval
+	}
+	return now.Sub(lastSeen) > after
+}
+
+// registerDeviceHandler registers a device expected to report, e.g.
+// {"id": "shellyplusht-abc", "name": "Bathroom", "aliases": ["a4:cf:12:f4:56:78"], "interval": "15m"}.
+// Registering a device again replaces its aliases and interval.
+func (m *MeasureServer) registerDeviceHandler(ctx *gin.Context) {
+	var req struct {
+		ID       string   `json:"id" binding:"required"`
+		Name     string   `json:"name"`
+		Aliases  []string `json:"aliases"`
+		Interval string   `json:"interval"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	device := m.ids.canonical(req.ID)
+	if device == "" {
+		abortWithError(ctx, http.StatusBadRequest, errors.New("id not set"))
+		return
+	}
+	if req.Interval != "" {
+		if interval, err := time.ParseDuration(req.Interval); err != nil || interval <= 0 {
+			abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("invalid interval %q", req.Interval))
+			return
+		}
+	}
+	var aliases []string
+	for _, alias := range req.Aliases {
+		alias = data.CanonicalID(alias)
+		if alias == "" || alias == device || slices.Contains(aliases, alias) {
+			continue
+		}
+		if other := m.ids.canonical(alias); other != alias && other != device {
+			abortWithError(ctx, http.StatusConflict, fmt.Errorf("alias %q is in use by %q", alias, other))
+			return
+		}
+		aliases = append(aliases, alias)
+	}
+
+	previous := m.registry.get(device).Aliases
+	var meta deviceMeta
+	if err := m.registry.update(device, func(d *deviceMeta) {
+		d.Registered = true
+		if name := strings.TrimSpace(req.Name); name != "" {
+			d.Name = name
+		}
+		d.Aliases = aliases
+		d.Interval = req.Interval
+		meta = *d
+	}); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	for _, alias := range previous {
+		if !slices.Contains(aliases, alias) {
+			m.ids.forget(alias)
+		}
+	}
+	for _, alias := range aliases {
+		if err := m.ids.alias(alias, device); err != nil {
+			abortWithError(ctx, http.StatusConflict, err)
+			return
+		}
+	}
+	m.Logger.Infof("registered device %q", device)
+	ctx.JSON(http.StatusOK, gin.H{
+		"device":   device,
+		"name":     meta.Name,
+		"aliases":  meta.Aliases,
+		"interval": meta.Interval,
+	})
+}
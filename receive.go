@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+)
+
+// maxRemoteWriteSize limits the decompressed size of remote_write requests, which are limited
+// to maxWriteSize compressed.
+const maxRemoteWriteSize = 16 << 20
+
+var (
+	remoteWriteDeviceLabels = flag.String("remoteWriteDeviceLabels", "device", "Comma separated labels of received remote_write series whose values, joined by -, form the device ID, e.g. model,id.")
+
+	remoteWriteReceive stringList
+)
+
+func init() {
+	flag.Var(&remoteWriteReceive, "remoteWriteReceive", "Receive remote_write series on /measure/v1/remote_write and record them as <metric>=<series name>, e.g. temperature=rtl_433_temperature_celsius. Can be repeated.")
+}
+
+// parseRemoteWriteReceive parses the -remoteWriteReceive mappings into series name -> metric.
+func parseRemoteWriteReceive(mappings []string) (map[string]string, error) {
+	series := map[string]string{}
+	for _, v := range mappings {
+		metric, name, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid mapping %q", v)
+		}
+		if !data.ValidMetric(metric) {
+			return nil, fmt.Errorf("invalid metric %q", metric)
+		}
+		series[name] = metric
+	}
+	return series, nil
+}
+
+// remoteWriteHandler accepts Prometheus remote_write requests, e.g. from exporters of 433 MHz
+// sensors, and records the samples of the mapped series. Samples of a device with the same
+// timestamp are recorded as one reading.
+func (m *MeasureServer) remoteWriteHandler(series map[string]string, deviceLabels []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		compressed, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxWriteSize))
+		if err != nil {
+			abortWithError(ctx, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		n, err := snappy.DecodedLen(compressed)
+		if err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if n > maxRemoteWriteSize {
+			abortWithError(ctx, http.StatusRequestEntityTooLarge, fmt.Errorf("decoded request of %d bytes exceeds limit of %d", n, maxRemoteWriteSize))
+			return
+		}
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		received, err := data.DecodeWriteRequest(body)
+		if err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		type key struct {
+			device string
+			ts     int64
+		}
+		readings := map[key]*reportReading{}
+		for _, ts := range received {
+			metric, ok := series[ts.Labels["__name__"]]
+			if !ok {
+				continue
+			}
+			parts := make([]string, 0, len(deviceLabels))
+			for _, l := range deviceLabels {
+				if v := ts.Labels[l]; v != "" {
+					parts = append(parts, v)
+				}
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			device := strings.Join(parts, "-")
+			for _, s := range ts.Samples {
+				k := key{device, s.Timestamp}
+				r, ok := readings[k]
+				if !ok {
+					t := float64(s.Timestamp) / 1000
+					r = &reportReading{ID: device, Timestamp: &t}
+					readings[k] = r
+				}
+				r.set(metric, s.Value)
+			}
+		}
+
+		// Record oldest first so the latest sample of every device ends up in the cache.
+		keys := make([]key, 0, len(readings))
+		for k := range readings {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].ts < keys[j].ts })
+		for _, k := range keys {
+			if !m.authorized(ctx, k.device) {
+				abortWithError(ctx, http.StatusForbidden, fmt.Errorf("not authorized to report device %q", k.device))
+				return
+			}
+		}
+		now := time.Now()
+		for _, k := range keys {
+			r := readings[k]
+			status, err := r.status()
+			if err != nil {
+				continue
+			}
+			msg, err := json.Marshal(status)
+			if err != nil {
+				continue
+			}
+			m.recordAt(ctx.Request.Context(), r.ID, json.RawMessage(msg), r.received(now))
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBatchSize = 1000
+
+// maxClockSkew is how far timestamps of readings may be ahead of the clock of the server, e.g.
+// because the clock of a device drifted.
+const maxClockSkew = 5 * time.Minute
+
+var reportGET = flag.Bool("reportGET", true, "Accept readings as GET requests to the report and gen1 endpoints for action URLs of existing devices. Deprecated in favor of POST.")
+
+// reportReading is a reading POSTed to the report endpoints. Timestamps are optional Unix
+// seconds and default to the time the reading is received. They must not be further ahead than
+// maxClockSkew.
+type reportReading struct {
+	ID          string   `json:"id" form:"id"`
+	Temperature *float64 `json:"temp" form:"temp"`
+	Fahrenheit  *float64 `json:"temp_f" form:"temp_f"`
+	Humidity    *float64 `json:"hum" form:"hum"`
+	Battery     *float64 `json:"battery" form:"battery"`
+	Timestamp   *float64 `json:"ts" form:"ts"`
+	// Metrics holds the values of other sensor types, e.g. {"pressure": 1013.2, "co2": 620}.
+	Metrics map[string]float64 `json:"metrics" form:"-"`
+}
+
+// set sets the value of metric.
+func (r *reportReading) set(metric string, v float64) {
+	switch metric {
+	case data.MetricTemperature:
+		r.Temperature = &v
+	case data.MetricHumidity:
+		r.Humidity = &v
+	case data.MetricBattery:
+		r.Battery = &v
+	default:
+		if r.Metrics == nil {
+			r.Metrics = map[string]float64{}
+		}
+		r.Metrics[metric] = v
+	}
+}
+
+func (r reportReading) status() (data.ReportStatus, error) {
+	temperature := celsius(r.Temperature, r.Fahrenheit)
+	if r.ID == "" || (temperature == nil && r.Humidity == nil && r.Battery == nil && len(r.Metrics) == 0) {
+		return data.ReportStatus{}, errors.New("not enough parameters set")
+	}
+	// Checked before converting to avoid overflows, see received.
+	if ts := r.Timestamp; ts != nil && (!(*ts > 0) || *ts > float64(time.Now().Add(maxClockSkew).Unix())) {
+		return data.ReportStatus{}, fmt.Errorf("timestamp %g is not between the Unix epoch and now", *ts)
+	}
+	status := data.ReportStatus{
+		Device:      r.ID,
+		Temperature: temperature,
+		Humidity:    r.Humidity,
+		Battery:     r.Battery,
+		Metrics:     r.Metrics,
+	}
+	return status, status.Validate()
+}
+
+// celsius returns the reported temperature in Celsius, converting it if it was reported in
+// Fahrenheit only.
+func celsius(c, f *float64) *float64 {
+	if c != nil || f == nil {
+		return c
+	}
+	v := data.FahrenheitToCelsius(*f)
+	return &v
+}
+
+// validReceived returns an error unless t is after the Unix epoch and at most maxClockSkew
+// ahead of now. Readings from the future would otherwise never be replaced in the cache.
+func validReceived(t, now time.Time) error {
+	if t.Unix() <= 0 || t.After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("timestamp %s is not between the Unix epoch and now", t.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (r reportReading) received(now time.Time) time.Time {
+	if r.Timestamp == nil {
+		return now
+	}
+	return time.UnixMilli(int64(*r.Timestamp * 1000))
+}
+
+// reportPostHandler accepts readings as JSON or form encoded body, e.g.
+// {"id": "sensor", "temp": 21.5, "hum": 45, "battery": 80, "metrics": {"pressure": 1013.2}}.
+func (m *MeasureServer) reportPostHandler(ctx *gin.Context) {
+	switch ctx.ContentType() {
+	case gin.MIMEJSON, gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm:
+	default:
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", ctx.ContentType()))
+		return
+	}
+	var body reportReading
+	if err := ctx.ShouldBind(&body); err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	r, err := body.status()
+	if err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if !m.authorized(ctx, r.Device) {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusForbidden, fmt.Errorf("not authorized to report device %q", r.Device))
+		return
+	}
+	msg, err := json.Marshal(r)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	m.recordAt(ctx.Request.Context(), r.Device, json.RawMessage(msg), body.received(time.Now()))
+	reportRequests.WithLabelValues("accepted").Inc()
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// reportBatchHandler accepts a JSON array of readings, e.g. buffered by a gateway while offline.
+// The batch is rejected as a whole if any reading is invalid.
+func (m *MeasureServer) reportBatchHandler(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxWriteSize)
+	var body []reportReading
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if len(body) > maxBatchSize {
+		reportRequests.WithLabelValues("rejected").Inc()
+		abortWithError(ctx, http.StatusRequestEntityTooLarge, fmt.Errorf("batch of %d readings exceeds limit of %d", len(body), maxBatchSize))
+		return
+	}
+
+	msgs := make([]json.RawMessage, len(body))
+	for i, b := range body {
+		r, err := b.status()
+		if err != nil {
+			reportRequests.WithLabelValues("rejected").Inc()
+			abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("reading %d: %s", i, err))
+			return
+		}
+		if !m.authorized(ctx, r.Device) {
+			reportRequests.WithLabelValues("rejected").Inc()
+			abortWithError(ctx, http.StatusForbidden, fmt.Errorf("reading %d: not authorized to report device %q", i, r.Device))
+			return
+		}
+		msg, err := json.Marshal(r)
+		if err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		msgs[i] = msg
+	}
+	now := time.Now()
+	for i, b := range body {
+		m.recordAt(ctx.Request.Context(), b.ID, msgs[i], b.received(now))
+	}
+	reportRequests.WithLabelValues("accepted").Add(float64(len(body)))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"accepted": len(body),
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/finfinack/measure/data"
+)
+
+// rollingBucket is the resolution of the rolling aggregates. Windows include up to one bucket
+// more than their duration.
+const rollingBucket = 5 * time.Minute
+
+// rollingWindows are the windows the rolling aggregates are computed for by name.
+var rollingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// rollingRetention is the longest window, older buckets are dropped.
+const rollingRetention = 24 * time.Hour
+
+// windowStats aggregates the values of a metric of a device within a window.
+type windowStats struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+}
+
+type bucket struct {
+	min, max, sum float64
+	count         int
+}
+
+// rolling keeps the minimum, maximum and average of every metric of a device in buckets of
+// rollingBucket so recent ranges are available without a persistent store.
+type rolling struct {
+	mu      sync.Mutex
+	devices map[string]map[string]map[int64]*bucket // device -> metric -> bucket index
+}
+
+func newRolling() *rolling {
+	return &rolling{
+		devices: map[string]map[string]map[int64]*bucket{},
+	}
+}
+
+func bucketIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(rollingBucket)
+}
+
+// add adds the metrics of a reading received at t. Readings older than the longest window are
+// ignored.
+func (r *rolling) add(device string, t time.Time, metrics map[string]float64) {
+	oldest := bucketIndex(time.Now().Add(-rollingRetention))
+	idx := bucketIndex(t)
+	if idx < oldest {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byMetric, ok := r.devices[device]
+	if !ok {
+		byMetric = map[string]map[int64]*bucket{}
+		r.devices[device] = byMetric
+	}
+	for metric, v := range metrics {
+		buckets, ok := byMetric[metric]
+		if !ok {
+			buckets = map[int64]*bucket{}
+			byMetric[metric] = buckets
+		}
+		for i := range buckets {
+			if i < oldest {
+				delete(buckets, i)
+			}
+		}
+		b, ok := buckets[idx]
+		if !ok {
+			b = &bucket{min: math.Inf(1), max: math.Inf(-1)}
+			buckets[idx] = b
+		}
+		b.min = math.Min(b.min, v)
+		b.max = math.Max(b.max, v)
+		b.sum += v
+		b.count++
+	}
+}
+
+func (r *rolling) delete(device string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.devices, device)
+}
+
+// stats returns the aggregates of a device by window and metric as of now. Temperatures are
+// returned in unit. Windows without readings are left out.
+func (r *rolling) stats(device string, now time.Time, unit string) map[string]map[string]windowStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byMetric, ok := r.devices[device]
+	if !ok {
+		return nil
+	}
+
+	stats := map[string]map[string]windowStats{}
+	for name, d := range rollingWindows {
+		from := bucketIndex(now.Add(-d))
+		for metric, buckets := range byMetric {
+			s := windowStats{Min: math.Inf(1), Max: math.Inf(-1)}
+			var sum float64
+			for i, b := range buckets {
+				if i < from {
+					continue
+				}
+				s.Min = math.Min(s.Min, b.min)
+				s.Max = math.Max(s.Max, b.max)
+				sum += b.sum
+				s.Count += b.count
+			}
+			if s.Count == 0 {
+				continue
+			}
+			s.Min = data.ConvertMetric(metric, s.Min, unit)
+			s.Max = data.ConvertMetric(metric, s.Max, unit)
+			s.Avg = math.Round(data.ConvertMetric(metric, sum/float64(s.Count), unit)*100) / 100
+			if stats[name] == nil {
+				stats[name] = map[string]windowStats{}
+			}
+			stats[name][metric] = s
+		}
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	return stats
+}
@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"errors"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+var errQueueFull = errors.New("sink queue is full, dropping reading")
+
+// BatchConfig controls how readings are grouped and retried by batching sinks.
+type BatchConfig struct {
+	Size          int
+	FlushInterval time.Duration
+	Retries       int
+}
+
+// batcher collects readings and hands them to flush once Size readings are queued or the
+// FlushInterval passed. Failed flushes are retried with exponential backoff.
+type batcher struct {
+	cfg    BatchConfig
+	flush  func([]store.Record) error
+	logger *logging.Logger
+
+	in   chan store.Record
+	done chan struct{}
+}
+
+func newBatcher(cfg BatchConfig, flush func([]store.Record) error, logger *logging.Logger) *batcher {
+	b := &batcher{
+		cfg:    cfg,
+		flush:  flush,
+		logger: logger,
+		in:     make(chan store.Record, cfg.Size*10),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *batcher) Write(r store.Record) error {
+	select {
+	case b.in <- r:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+func (b *batcher) Close() error {
+	close(b.in)
+	<-b.done
+	return nil
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []store.Record
+	for {
+		select {
+		case r, ok := <-b.in:
+			if !ok {
+				b.send(batch)
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= b.cfg.Size {
+				b.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			b.send(batch)
+			batch = nil
+		}
+	}
+}
+
+func (b *batcher) send(batch []store.Record) {
+	if len(batch) == 0 {
+		return
+	}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := b.flush(batch)
+		if err == nil {
+			return
+		}
+		if attempt >= b.cfg.Retries {
+			b.logger.Warnf("dropping %d readings after %d attempts: %s", len(batch), attempt+1, err)
+			return
+		}
+		b.logger.Debugf("flush failed, retrying in %s: %s", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+const clickHouseTimeFormat = "2006-01-02 15:04:05.000"
+
+var clickHouseIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ClickHouse inserts readings into a ClickHouse table using the HTTP interface.
+type ClickHouse struct {
+	*batcher
+
+	addr     string
+	table    string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// NewClickHouse creates the table (one row per device, metric and timestamp) if it does not
+// exist yet and starts batching inserts into it.
+func NewClickHouse(addr, table, user, password string, cfg BatchConfig, logger *logging.Logger) (*ClickHouse, error) {
+	if !clickHouseIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid ClickHouse table name %q", table)
+	}
+	c := &ClickHouse{
+		addr:     addr,
+		table:    table,
+		user:     user,
+		password: password,
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+	if err := c.query(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		device LowCardinality(String),
+		metric LowCardinality(String),
+		ts     DateTime64(3, 'UTC'),
+		value  Float64
+	) ENGINE = MergeTree ORDER BY (device, metric, ts)`, table), nil); err != nil {
+		return nil, fmt.Errorf("unable to create ClickHouse table: %s", err)
+	}
+	c.batcher = newBatcher(cfg, c.flush, logger)
+	return c, nil
+}
+
+func (c *ClickHouse) flush(records []store.Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		for metric, value := range r.Reading.Metrics() {
+			if err := enc.Encode(map[string]any{
+				"device": r.Device,
+				"metric": metric,
+				"ts":     r.Received.UTC().Format(clickHouseTimeFormat),
+				"value":  value,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return c.query(fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", c.table), &buf)
+}
+
+func (c *ClickHouse) query(query string, body io.Reader) error {
+	u, err := url.Parse(c.addr)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = url.Values{"query": {query}}.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), body)
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ClickHouse returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/finfinack/logger/logging"
+)
+
+const eventQueueSize = 100
+
+// EventHook POSTs device events as JSON to a URL. Events are sent in the background and
+// dropped if the queue is full or the target fails.
+type EventHook struct {
+	url    string
+	client *http.Client
+	logger *logging.Logger
+
+	in   chan data.Event
+	done chan struct{}
+}
+
+func NewEventHook(url string, logger *logging.Logger) *EventHook {
+	h := &EventHook{
+		url:    url,
+		client: &http.Client{Timeout: httpTimeout},
+		logger: logger,
+		in:     make(chan data.Event, eventQueueSize),
+		done:   make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *EventHook) Send(e data.Event) error {
+	select {
+	case h.in <- e:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+func (h *EventHook) run() {
+	defer close(h.done)
+	for e := range h.in {
+		if err := h.post(e); err != nil {
+			h.logger.Warnf("unable to send event of %q: %s", e.Device, err)
+		}
+	}
+}
+
+func (h *EventHook) post(e data.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("event hook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *EventHook) Close() error {
+	close(h.in)
+	<-h.done
+	return nil
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+const dialTimeout = 5 * time.Second
+
+// Graphite writes readings using the Carbon plaintext protocol as
+// <prefix>.<device>.<metric> <value> <timestamp>.
+type Graphite struct {
+	*batcher
+
+	addr   string
+	prefix string
+	conn   net.Conn
+}
+
+func NewGraphite(addr, prefix string, cfg BatchConfig, logger *logging.Logger) *Graphite {
+	g := &Graphite{
+		addr:   addr,
+		prefix: prefix,
+	}
+	g.batcher = newBatcher(cfg, g.flush, logger)
+	return g
+}
+
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_")
+
+func (g *Graphite) flush(records []store.Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		metrics := r.Reading.Metrics()
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := graphiteEscaper.Replace(r.Device) + "." + graphiteEscaper.Replace(name)
+			if g.prefix != "" {
+				path = g.prefix + "." + path
+			}
+			fmt.Fprintf(&buf, "%s %s %d\n", path, strconv.FormatFloat(metrics[name], 'f', -1, 64), r.Received.Unix())
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	// The connection is kept open between flushes and re-established on failure.
+	if g.conn == nil {
+		conn, err := net.DialTimeout("tcp", g.addr, dialTimeout)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+	g.conn.SetWriteDeadline(time.Now().Add(httpTimeout))
+	if _, err := g.conn.Write(buf.Bytes()); err != nil {
+		g.conn.Close()
+		g.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (g *Graphite) Close() error {
+	g.batcher.Close()
+	if g.conn != nil {
+		return g.conn.Close()
+	}
+	return nil
+}
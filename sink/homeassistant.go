@@ -0,0 +1,76 @@
+package sink
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/finfinack/measure/data"
+)
+
+// haSensor describes how a metric is presented in Home Assistant.
+type haSensor struct {
+	name        string
+	deviceClass string
+	unit        string
+}
+
+var haSensors = map[string]haSensor{
+	data.MetricTemperature: {name: "Temperature", deviceClass: "temperature", unit: "°C"},
+	data.MetricHumidity:    {name: "Humidity", deviceClass: "humidity", unit: "%"},
+	data.MetricBattery:     {name: "Battery", deviceClass: "battery", unit: "%"},
+}
+
+// haDiscovery announces sensors to Home Assistant using MQTT discovery, once per device and
+// metric and process lifetime. Announcements are retained so Home Assistant picks them up after
+// restarts as well.
+type haDiscovery struct {
+	prefix string
+
+	mu        sync.Mutex
+	announced map[string]bool
+}
+
+func newHADiscovery(prefix string) *haDiscovery {
+	return &haDiscovery{
+		prefix:    prefix,
+		announced: map[string]bool{},
+	}
+}
+
+var haIDEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_", ".", "_", ":", "_")
+
+// config returns the discovery topic and payload for a metric of a device if it has not been
+// announced yet.
+func (d *haDiscovery) config(device, metric, stateTopic string) (string, []byte, bool) {
+	sensor, ok := haSensors[metric]
+	if !ok {
+		return "", nil, false
+	}
+	id := haIDEscaper.Replace(device)
+	objectID := id + "_" + metric
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.announced[objectID] {
+		return "", nil, false
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"name":                sensor.name,
+		"unique_id":           "measure_" + objectID,
+		"state_topic":         stateTopic,
+		"device_class":        sensor.deviceClass,
+		"unit_of_measurement": sensor.unit,
+		"state_class":         "measurement",
+		"device": map[string]any{
+			"identifiers": []string{"measure_" + id},
+			"name":        device,
+		},
+	})
+	if err != nil {
+		return "", nil, false
+	}
+	d.announced[objectID] = true
+	return d.prefix + "/sensor/" + id + "/" + metric + "/config", payload, true
+}
@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+const httpTimeout = 10 * time.Second
+
+// Influx writes readings to an InfluxDB v2 bucket using the line protocol.
+type Influx struct {
+	*batcher
+
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func NewInflux(addr, org, bucket, token string, cfg BatchConfig, logger *logging.Logger) (*Influx, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB URL %q: %s", addr, err)
+	}
+	u = u.JoinPath("/api/v2/write")
+	u.RawQuery = url.Values{
+		"org":       {org},
+		"bucket":    {bucket},
+		"precision": {"ns"},
+	}.Encode()
+
+	i := &Influx{
+		endpoint: u.String(),
+		token:    token,
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+	i.batcher = newBatcher(cfg, i.flush, logger)
+	return i, nil
+}
+
+func (i *Influx) flush(records []store.Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		buf.WriteString(lineProtocol(r))
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.endpoint, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.token != "" {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// lineProtocol formats a reading as a single line protocol point or returns an empty string if
+// the reading has no metrics.
+func lineProtocol(r store.Record) string {
+	metrics := r.Reading.Metrics()
+	if len(metrics) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, tagEscaper.Replace(name)+"="+strconv.FormatFloat(metrics[name], 'f', -1, 64))
+	}
+	return fmt.Sprintf("measure,device=%s %s %d\n", tagEscaper.Replace(r.Device), strings.Join(fields, ","), r.Received.UnixNano())
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka produces one JSON event per reading keyed by device, so all readings of a device end up
+// in the same partition.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+func NewKafka(brokers, topic string, cfg BatchConfig, logger *logging.Logger) *Kafka {
+	return &Kafka{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(brokers, ",")...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    cfg.Size,
+			BatchTimeout: cfg.FlushInterval,
+			MaxAttempts:  cfg.Retries + 1,
+			RequiredAcks: kafka.RequireOne,
+			Async:        true,
+			Completion: func(messages []kafka.Message, err error) {
+				if err != nil {
+					logger.Warnf("unable to produce %d messages: %s", len(messages), err)
+				}
+			},
+		},
+	}
+}
+
+func (k *Kafka) Write(r store.Record) error {
+	value, err := json.Marshal(NewEvent(r))
+	if err != nil {
+		return err
+	}
+	// Async writes return immediately, errors are reported to Completion.
+	return k.writer.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(r.Device),
+		Value: value,
+		Time:  r.Received,
+	})
+}
+
+func (k *Kafka) Close() error {
+	done := make(chan error, 1)
+	go func() { done <- k.writer.Close() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(httpTimeout):
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/finfinack/logger/logging"
+)
+
+// MQTTConfig configures the connection to an MQTT broker.
+type MQTTConfig struct {
+	Broker   string // e.g. tcp://localhost:1883
+	ClientID string
+	Username string
+	Password string
+	QoS      byte
+
+	OnConnect mqtt.OnConnectHandler // optional, called after every (re)connect
+}
+
+// NewMQTTClient connects to the broker and keeps reconnecting if the connection is lost.
+func NewMQTTClient(cfg MQTTConfig, logger *logging.Logger) (mqtt.Client, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.Warnf("connection to MQTT broker lost: %s", err)
+		})
+	if cfg.OnConnect != nil {
+		opts.SetOnConnectHandler(cfg.OnConnect)
+	}
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(dialTimeout) {
+		return nil, fmt.Errorf("timed out connecting to MQTT broker %q", cfg.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("unable to connect to MQTT broker %q: %s", cfg.Broker, err)
+	}
+	return client, nil
+}
+
+// MQTT publishes every metric of a reading as a retained message. The topic is derived from a
+// template where {device} and {metric} are replaced, e.g. measure/{device}/{metric}.
+// If a discovery prefix is set, Home Assistant discovery configs are published as well.
+type MQTT struct {
+	client    mqtt.Client
+	topic     string
+	qos       byte
+	discovery *haDiscovery // optional
+	logger    *logging.Logger
+}
+
+func NewMQTT(cfg MQTTConfig, topic, discoveryPrefix string, logger *logging.Logger) (*MQTT, error) {
+	client, err := NewMQTTClient(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	m := &MQTT{
+		client: client,
+		topic:  topic,
+		qos:    cfg.QoS,
+		logger: logger,
+	}
+	if discoveryPrefix != "" {
+		m.discovery = newHADiscovery(discoveryPrefix)
+	}
+	return m, nil
+}
+
+var topicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+func (m *MQTT) topicFor(device, metric string) string {
+	return strings.NewReplacer(
+		"{device}", topicEscaper.Replace(device),
+		"{metric}", topicEscaper.Replace(metric),
+	).Replace(m.topic)
+}
+
+func (m *MQTT) Write(r store.Record) error {
+	for metric, value := range r.Reading.Metrics() {
+		topic := m.topicFor(r.Device, metric)
+		if m.discovery != nil {
+			if configTopic, config, ok := m.discovery.config(r.Device, metric, topic); ok {
+				m.publish(configTopic, config)
+			}
+		}
+		m.publish(topic, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return nil
+}
+
+// publish sends a retained message without waiting for the broker to acknowledge it.
+func (m *MQTT) publish(topic string, payload any) {
+	token := m.client.Publish(topic, m.qos, true, payload)
+	go func() {
+		if token.WaitTimeout(httpTimeout) && token.Error() != nil {
+			m.logger.Warnf("unable to publish to %q: %s", topic, token.Error())
+		}
+	}()
+}
+
+func (m *MQTT) Close() error {
+	m.client.Disconnect(uint(time.Second / time.Millisecond))
+	return nil
+}
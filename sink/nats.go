@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes one JSON event per reading to <prefix>.<device>. Every message carries a
+// Nats-Msg-Id header so JetStream streams can de-duplicate redeliveries.
+type NATS struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNATS(url, prefix string, logger *logging.Logger) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("measure"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logger.Warnf("disconnected from NATS: %s", err)
+			}
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to NATS %q: %s", url, err)
+	}
+	return &NATS{
+		conn:   conn,
+		prefix: prefix,
+	}, nil
+}
+
+// Subjects must not contain whitespace and tokens are separated by dots.
+var subjectEscaper = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_", "\t", "_")
+
+func (n *NATS) Write(r store.Record) error {
+	data, err := json.Marshal(NewEvent(r))
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(n.prefix + "." + subjectEscaper.Replace(r.Device))
+	msg.Data = data
+	msg.Header.Set("Content-Type", "application/json")
+	msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s-%d", r.Device, r.Received.UnixNano()))
+	// Publishing is buffered by the client and does not block on the network.
+	return n.conn.PublishMsg(msg)
+}
+
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
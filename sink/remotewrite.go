@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// prometheusNames maps metrics to the Prometheus metric names they are exported as.
+var prometheusNames = map[string]string{
+	data.MetricTemperature: "measure_temperature_celsius",
+	data.MetricHumidity:    "measure_humidity_percent",
+	data.MetricBattery:     "measure_battery_percent",
+	data.MetricDewPoint:    "measure_dew_point_celsius",
+	data.MetricHeatIndex:   "measure_heat_index_celsius",
+
+	data.MetricAbsoluteHumidity: "measure_absolute_humidity_grams_per_cubic_meter",
+	data.MetricVPD:              "measure_vpd_kilopascals",
+
+	data.MetricPressure:    "measure_pressure_hpa",
+	data.MetricIlluminance: "measure_illuminance_lux",
+	data.MetricCO2:         "measure_co2_ppm",
+}
+
+// PrometheusName returns the Prometheus metric name of a metric.
+func PrometheusName(metric string) string {
+	if n, ok := prometheusNames[metric]; ok {
+		return n
+	}
+	return "measure_" + metric
+}
+
+// RemoteWrite sends readings to a Prometheus remote_write endpoint (e.g. Mimir, VictoriaMetrics).
+type RemoteWrite struct {
+	*batcher
+
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewRemoteWrite(endpoint, username, password string, cfg BatchConfig, logger *logging.Logger) *RemoteWrite {
+	rw := &RemoteWrite{
+		endpoint: endpoint,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+	rw.batcher = newBatcher(cfg, rw.flush, logger)
+	return rw
+}
+
+func (rw *RemoteWrite) flush(records []store.Record) error {
+	body := encodeWriteRequest(records)
+	if len(body) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rw.endpoint, bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if rw.username != "" {
+		req.SetBasicAuth(rw.username, rw.password)
+	}
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote_write endpoint returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes readings as a prometheus.WriteRequest protobuf message with one
+// time series per device and metric.
+func encodeWriteRequest(records []store.Record) []byte {
+	var req []byte
+	for _, r := range records {
+		metrics := r.Reading.Metrics()
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			var ts []byte
+			// Labels must be sorted by name.
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, encodeLabel("__name__", PrometheusName(name)))
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, encodeLabel("device", r.Device))
+
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(metrics[name]))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(r.Received.UnixMilli()))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sample)
+
+			req = protowire.AppendTag(req, 1, protowire.BytesType)
+			req = protowire.AppendBytes(req, ts)
+		}
+	}
+	return req
+}
+
+func encodeLabel(name, value string) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, name)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, value)
+	return b
+}
@@ -0,0 +1,29 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/finfinack/measure/store"
+)
+
+// Sink receives every reading to export it to an external system.
+type Sink interface {
+	// Write hands a reading to the sink. Sinks must not block the caller on network I/O.
+	Write(r store.Record) error
+	Close() error
+}
+
+// Event is the JSON representation of a reading used by message based sinks.
+type Event struct {
+	Device   string             `json:"device"`
+	Received time.Time          `json:"received"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+func NewEvent(r store.Record) Event {
+	return Event{
+		Device:   r.Device,
+		Received: r.Received,
+		Metrics:  r.Reading.Metrics(),
+	}
+}
@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/finfinack/measure/store"
+)
+
+// StatsD sends readings as gauges via UDP. With DogStatsD tags enabled, the device is sent as tag
+// (<prefix>.<metric>:<value>|g|#device:<device>), otherwise it is part of the metric name
+// (<prefix>.<device>.<metric>:<value>|g).
+type StatsD struct {
+	prefix string
+	tags   bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewStatsD(addr, prefix string, tags bool) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up StatsD client for %q: %s", addr, err)
+	}
+	return &StatsD{
+		prefix: prefix,
+		tags:   tags,
+		conn:   conn,
+	}, nil
+}
+
+var statsdEscaper = strings.NewReplacer(".", "_", ":", "_", "|", "_", "#", "_", ",", "_", " ", "_")
+
+func (s *StatsD) name(parts ...string) string {
+	escaped := make([]string, 0, len(parts)+1)
+	if s.prefix != "" {
+		escaped = append(escaped, s.prefix)
+	}
+	for _, p := range parts {
+		escaped = append(escaped, statsdEscaper.Replace(p))
+	}
+	return strings.Join(escaped, ".")
+}
+
+func (s *StatsD) Write(r store.Record) error {
+	for metric, value := range r.Reading.Metrics() {
+		v := strconv.FormatFloat(value, 'f', -1, 64)
+		if s.tags {
+			s.send(fmt.Sprintf("%s:%s|g|#device:%s", s.name(metric), v, statsdEscaper.Replace(r.Device)))
+		} else {
+			s.send(fmt.Sprintf("%s:%s|g", s.name(r.Device, metric), v))
+		}
+	}
+	return nil
+}
+
+// Count sends a counter increment. Tags are only sent if DogStatsD tags are enabled and are
+// folded into the metric name otherwise.
+func (s *StatsD) Count(name string, delta float64, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	v := strconv.FormatFloat(delta, 'f', -1, 64)
+	if s.tags {
+		line := fmt.Sprintf("%s:%s|c", s.name(name), v)
+		if len(keys) > 0 {
+			pairs := make([]string, 0, len(keys))
+			for _, k := range keys {
+				pairs = append(pairs, statsdEscaper.Replace(k)+":"+statsdEscaper.Replace(tags[k]))
+			}
+			line += "|#" + strings.Join(pairs, ",")
+		}
+		s.send(line)
+		return
+	}
+	parts := []string{name}
+	for _, k := range keys {
+		parts = append(parts, tags[k])
+	}
+	s.send(fmt.Sprintf("%s:%s|c", s.name(parts...), v))
+}
+
+// send writes a single packet. StatsD is fire and forget, so errors are ignored.
+func (s *StatsD) send(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.Write([]byte(line))
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+// VictoriaMetrics pushes readings using the JSON line format of /api/v1/import which is cheaper
+// to produce than remote_write.
+type VictoriaMetrics struct {
+	*batcher
+
+	endpoint string
+	client   *http.Client
+}
+
+type vmSeries struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+func NewVictoriaMetrics(addr string, cfg BatchConfig, logger *logging.Logger) (*VictoriaMetrics, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VictoriaMetrics URL %q: %s", addr, err)
+	}
+	vm := &VictoriaMetrics{
+		endpoint: u.JoinPath("/api/v1/import").String(),
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+	vm.batcher = newBatcher(cfg, vm.flush, logger)
+	return vm, nil
+}
+
+func (vm *VictoriaMetrics) flush(records []store.Record) error {
+	// Group samples into one line per series.
+	type seriesKey struct{ device, name string }
+	var order []seriesKey
+	series := map[seriesKey]*vmSeries{}
+	for _, r := range records {
+		for metric, value := range r.Reading.Metrics() {
+			k := seriesKey{device: r.Device, name: PrometheusName(metric)}
+			s, ok := series[k]
+			if !ok {
+				s = &vmSeries{Metric: map[string]string{"__name__": k.name, "device": k.device}}
+				series[k] = s
+				order = append(order, k)
+			}
+			s.Values = append(s.Values, value)
+			s.Timestamps = append(s.Timestamps, r.Received.UnixMilli())
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, k := range order {
+		if err := enc.Encode(series[k]); err != nil {
+			return err
+		}
+	}
+	resp, err := vm.client.Post(vm.endpoint, "application/json", &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("VictoriaMetrics returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+// WebhookTarget is a URL readings are POSTed to. Readings can be filtered by a device glob
+// (see path.Match) and a list of metrics.
+type WebhookTarget struct {
+	URL     string
+	Devices string   // optional
+	Metrics []string // optional
+}
+
+// Webhook POSTs every matching reading as JSON event to its target.
+type Webhook struct {
+	*batcher
+
+	target WebhookTarget
+	client *http.Client
+}
+
+func NewWebhook(target WebhookTarget, cfg BatchConfig, logger *logging.Logger) (*Webhook, error) {
+	if target.Devices != "" {
+		if _, err := path.Match(target.Devices, ""); err != nil {
+			return nil, fmt.Errorf("invalid device glob %q: %s", target.Devices, err)
+		}
+	}
+	w := &Webhook{
+		target: target,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+	// Every reading is sent on its own.
+	cfg.Size = 1
+	w.batcher = newBatcher(cfg, w.flush, logger)
+	return w, nil
+}
+
+func (w *Webhook) Write(r store.Record) error {
+	if w.target.Devices != "" {
+		if ok, _ := path.Match(w.target.Devices, r.Device); !ok {
+			return nil
+		}
+	}
+	return w.batcher.Write(r)
+}
+
+// event returns the event of a reading restricted to the metrics of the target.
+func (w *Webhook) event(r store.Record) (Event, bool) {
+	e := NewEvent(r)
+	if len(w.target.Metrics) == 0 {
+		return e, len(e.Metrics) > 0
+	}
+	filtered := map[string]float64{}
+	for _, name := range w.target.Metrics {
+		if v, ok := e.Metrics[name]; ok {
+			filtered[name] = v
+		}
+	}
+	e.Metrics = filtered
+	return e, len(filtered) > 0
+}
+
+func (w *Webhook) flush(records []store.Record) error {
+	for _, r := range records {
+		e, ok := w.event(r)
+		if !ok {
+			continue
+		}
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		resp, err := w.client.Post(w.target.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook %q returned %s", w.target.URL, resp.Status)
+		}
+	}
+	return nil
+}
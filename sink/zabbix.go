@@ -0,0 +1,143 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/finfinack/logger/logging"
+)
+
+const (
+	zabbixHeader      = "ZBXD\x01"
+	zabbixMaxResponse = 1 << 16
+)
+
+// Zabbix sends readings to a Zabbix server or proxy using the trapper (zabbix_sender) protocol.
+// Every metric becomes an item keyed <prefix>.<metric>[<device>] on the configured host, matching
+// trapper items created from a low-level discovery rule or by hand.
+type Zabbix struct {
+	*batcher
+
+	addr   string
+	host   string
+	prefix string
+	logger *logging.Logger
+}
+
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int    `json:"ns"`
+}
+
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+}
+
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+func NewZabbix(addr, host, prefix string, cfg BatchConfig, logger *logging.Logger) *Zabbix {
+	z := &Zabbix{
+		addr:   addr,
+		host:   host,
+		prefix: prefix,
+		logger: logger,
+	}
+	z.batcher = newBatcher(cfg, z.flush, logger)
+	return z
+}
+
+// zabbixKeyParam quotes an item key parameter if it contains characters with special meaning.
+func zabbixKeyParam(p string) string {
+	if !strings.ContainsAny(p, `,]["' `) {
+		return p
+	}
+	return `"` + strings.ReplaceAll(p, `"`, `\"`) + `"`
+}
+
+func (z *Zabbix) flush(records []store.Record) error {
+	req := zabbixRequest{Request: "sender data"}
+	for _, r := range records {
+		metrics := r.Reading.Metrics()
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			req.Data = append(req.Data, zabbixItem{
+				Host:  z.host,
+				Key:   fmt.Sprintf("%s.%s[%s]", z.prefix, name, zabbixKeyParam(r.Device)),
+				Value: strconv.FormatFloat(metrics[name], 'f', -1, 64),
+				Clock: r.Received.Unix(),
+				NS:    r.Received.Nanosecond(),
+			})
+		}
+	}
+	if len(req.Data) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	// Zabbix closes the connection after every request.
+	conn, err := net.DialTimeout("tcp", z.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(httpTimeout))
+
+	var buf bytes.Buffer
+	buf.WriteString(zabbixHeader)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(body)))
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // reserved
+	buf.Write(body)
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("unable to read Zabbix response: %s", err)
+	}
+	if string(header[:len(zabbixHeader)]) != zabbixHeader {
+		return fmt.Errorf("invalid Zabbix response header %q", header)
+	}
+	size := binary.LittleEndian.Uint32(header[len(zabbixHeader):])
+	if size > zabbixMaxResponse {
+		return fmt.Errorf("Zabbix response of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("unable to read Zabbix response: %s", err)
+	}
+	var resp zabbixResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return fmt.Errorf("unable to parse Zabbix response: %s", err)
+	}
+	if resp.Response != "success" {
+		return fmt.Errorf("Zabbix rejected readings: %s", resp.Info)
+	}
+	// Items unknown to Zabbix are reported as failed but retrying them would not help.
+	z.logger.Debugf("sent %d items to Zabbix: %s", len(req.Data), resp.Info)
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/sink"
+
+	"github.com/finfinack/logger/logging"
+)
+
+var (
+	sinkBatchSize     = flag.Int("sinkBatchSize", 100, "Number of readings sent to external sinks in one batch.")
+	sinkFlushInterval = flag.Duration("sinkFlushInterval", 10*time.Second, "Maximum time readings are queued before they are sent to external sinks.")
+	sinkRetries       = flag.Int("sinkRetries", 3, "Number of times a failed batch is retried before it is dropped.")
+
+	influxURL    = flag.String("influxURL", "", "URL of the InfluxDB v2 server to write readings to. Empty disables the InfluxDB sink.")
+	influxOrg    = flag.String("influxOrg", "", "InfluxDB organization.")
+	influxBucket = flag.String("influxBucket", "measure", "InfluxDB bucket.")
+	influxToken  = flag.String("influxToken", "", "InfluxDB API token.")
+
+	remoteWriteURL      = flag.String("remoteWriteURL", "", "Prometheus remote_write endpoint to send readings to. Empty disables remote_write.")
+	remoteWriteUser     = flag.String("remoteWriteUser", "", "Username for basic authentication against the remote_write endpoint.")
+	remoteWritePassword = flag.String("remoteWritePassword", "", "Password for basic authentication against the remote_write endpoint.")
+
+	victoriaMetricsURL = flag.String("victoriaMetricsURL", "", "URL of the VictoriaMetrics server to import readings into, e.g. http://localhost:8428. Empty disables the VictoriaMetrics sink.")
+
+	clickHouseURL      = flag.String("clickHouseURL", "", "URL of the ClickHouse HTTP interface to insert readings into, e.g. http://localhost:8123. Empty disables the ClickHouse sink.")
+	clickHouseTable    = flag.String("clickHouseTable", "measure_readings", "ClickHouse table readings are inserted into.")
+	clickHouseUser     = flag.String("clickHouseUser", "", "ClickHouse user.")
+	clickHousePassword = flag.String("clickHousePassword", "", "ClickHouse password.")
+
+	graphiteAddr   = flag.String("graphiteAddr", "", "Address (host:port) of the Carbon plaintext receiver to send readings to. Empty disables the Graphite sink.")
+	graphitePrefix = flag.String("graphitePrefix", "measure", "Prefix of all metric paths sent to Graphite.")
+
+	zabbixAddr      = flag.String("zabbixAddr", "", "Address (host:port) of the Zabbix server or proxy to send readings to. Empty disables the Zabbix sender.")
+	zabbixHost      = flag.String("zabbixHost", "measure", "Zabbix host the trapper items belong to.")
+	zabbixKeyPrefix = flag.String("zabbixKeyPrefix", "measure", "Prefix of the Zabbix item keys, readings are sent as <prefix>.<metric>[<device>].")
+
+	mqttBroker   = flag.String("mqttBroker", "", "MQTT broker to publish readings to or receive them from, e.g. tcp://localhost:1883. Empty disables MQTT.")
+	mqttClientID = flag.String("mqttClientID", "measure", "Client ID used to connect to the MQTT broker.")
+	mqttUser     = flag.String("mqttUser", "", "Username used to connect to the MQTT broker.")
+	mqttPassword = flag.String("mqttPassword", "", "Password used to connect to the MQTT broker.")
+	mqttQoS      = flag.Uint("mqttQoS", 1, "QoS of published MQTT messages and subscriptions.")
+	mqttTopic    = flag.String("mqttTopic", "measure/{device}/{metric}", "Topic readings are published to. {device} and {metric} are replaced. Empty disables publishing.")
+
+	mqttDiscoveryPrefix = flag.String("mqttDiscoveryPrefix", "", "Home Assistant MQTT discovery prefix, usually homeassistant. Empty disables discovery.")
+
+	kafkaBrokers = flag.String("kafkaBrokers", "", "Comma separated list of Kafka brokers (host:port) to produce readings to. Empty disables the Kafka sink.")
+	kafkaTopic   = flag.String("kafkaTopic", "measure-readings", "Kafka topic readings are produced to.")
+
+	natsURL     = flag.String("natsURL", "", "NATS server to publish readings to, e.g. nats://localhost:4222. Empty disables the NATS sink.")
+	natsSubject = flag.String("natsSubject", "measure.readings", "Subject prefix readings are published to, the device ID is appended.")
+
+	statsdAddr   = flag.String("statsdAddr", "", "Address (host:port) of the StatsD server readings and counters are sent to. Empty disables StatsD.")
+	statsdPrefix = flag.String("statsdPrefix", "measure", "Prefix of all StatsD metric names.")
+	statsdTags   = flag.Bool("statsdTags", false, "Use DogStatsD tags instead of encoding the device into metric names.")
+
+	mqttSubscribe stringList
+	webhooks      stringList
+)
+
+func init() {
+	flag.Var(&mqttSubscribe, "mqttSubscribe", "Topic to receive Shelly RPC notifications from, e.g. shellies/+/events/rpc. Can be repeated.")
+	flag.Var(&webhooks, "webhook", "Webhook readings are POSTed to as <url>[,device=<glob>][,metric=<name>]... Can be repeated.")
+}
+
+func newSinks() ([]sink.Sink, error) {
+	cfg := sink.BatchConfig{
+		Size:          *sinkBatchSize,
+		FlushInterval: *sinkFlushInterval,
+		Retries:       *sinkRetries,
+	}
+	var sinks []sink.Sink
+	if *influxURL != "" {
+		s, err := sink.NewInflux(*influxURL, *influxOrg, *influxBucket, *influxToken, cfg, logging.NewLogger("INFX"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if *remoteWriteURL != "" {
+		sinks = append(sinks, sink.NewRemoteWrite(*remoteWriteURL, *remoteWriteUser, *remoteWritePassword, cfg, logging.NewLogger("PROM")))
+	}
+	if *victoriaMetricsURL != "" {
+		s, err := sink.NewVictoriaMetrics(*victoriaMetricsURL, cfg, logging.NewLogger("VICM"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if *clickHouseURL != "" {
+		s, err := sink.NewClickHouse(*clickHouseURL, *clickHouseTable, *clickHouseUser, *clickHousePassword, cfg, logging.NewLogger("CLICK"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if *graphiteAddr != "" {
+		sinks = append(sinks, sink.NewGraphite(*graphiteAddr, *graphitePrefix, cfg, logging.NewLogger("GRPH")))
+	}
+	if *zabbixAddr != "" {
+		sinks = append(sinks, sink.NewZabbix(*zabbixAddr, *zabbixHost, *zabbixKeyPrefix, cfg, logging.NewLogger("ZBBX")))
+	}
+	if *mqttBroker != "" && *mqttTopic != "" {
+		s, err := sink.NewMQTT(mqttConfig(), *mqttTopic, *mqttDiscoveryPrefix, logging.NewLogger("MQTT"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if *kafkaBrokers != "" {
+		sinks = append(sinks, sink.NewKafka(*kafkaBrokers, *kafkaTopic, cfg, logging.NewLogger("KAFK")))
+	}
+	if *natsURL != "" {
+		s, err := sink.NewNATS(*natsURL, *natsSubject, logging.NewLogger("NATS"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	for _, w := range webhooks {
+		target, err := parseWebhook(w)
+		if err != nil {
+			return nil, err
+		}
+		s, err := sink.NewWebhook(target, cfg, logging.NewLogger("HOOK"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// parseWebhook parses a webhook flag value of the form <url>[,device=<glob>][,metric=<name>]...
+func parseWebhook(v string) (sink.WebhookTarget, error) {
+	parts := strings.Split(v, ",")
+	target := sink.WebhookTarget{URL: parts[0]}
+	if target.URL == "" {
+		return target, fmt.Errorf("webhook %q has no URL", v)
+	}
+	for _, p := range parts[1:] {
+		key, value, _ := strings.Cut(p, "=")
+		switch key {
+		case "device":
+			target.Devices = value
+		case "metric":
+			target.Metrics = append(target.Metrics, value)
+		default:
+			return target, fmt.Errorf("webhook %q has unknown option %q", v, key)
+		}
+	}
+	return target, nil
+}
+
+func mqttConfig() sink.MQTTConfig {
+	return sink.MQTTConfig{
+		Broker:   *mqttBroker,
+		ClientID: *mqttClientID,
+		Username: *mqttUser,
+		Password: *mqttPassword,
+		QoS:      byte(*mqttQoS),
+	}
+}
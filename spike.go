@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	spikeWindow     = flag.Int("spikeWindow", 0, "Number of recent values of a metric whose median a new value is compared to in order to drop single-sample spikes. 0 disables the spike filter.")
+	spikeThresholds = flag.String("spikeThresholds", "temperature=5,humidity=20", "Comma separated <metric>=<deviation> pairs of how far a value may deviate from the median of recent values before the reading is dropped as spike. Other metrics are not filtered.")
+
+	spikeReadings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "measure_spike_readings_total",
+		Help: "Number of readings dropped as spike by metric.",
+	}, []string{"metric"})
+)
+
+// spikeFilter drops readings with a value deviating too much from the median of the recent values
+// of its metric. Dropped values are remembered as well, so a lasting change passes once it
+// makes up half of the window.
+type spikeFilter struct {
+	window     int
+	thresholds map[string]float64
+
+	mu     sync.Mutex
+	recent map[string]map[string][]float64 // device -> metric -> values, oldest first
+}
+
+func newSpikeFilter(window int, thresholds map[string]float64) *spikeFilter {
+	return &spikeFilter{
+		window:     window,
+		thresholds: thresholds,
+		recent:     map[string]map[string][]float64{},
+	}
+}
+
+// spikes returns the metrics of a reading which are spikes. Metrics are only checked once the
+// window is full.
+func (f *spikeFilter) spikes(r store.Record) []string {
+	if f.window <= 0 {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	byMetric, ok := f.recent[r.Device]
+	if !ok {
+		byMetric = map[string][]float64{}
+		f.recent[r.Device] = byMetric
+	}
+
+	var spikes []string
+	for metric, v := range r.Reading.Metrics() {
+		threshold, ok := f.thresholds[metric]
+		if !ok {
+			continue
+		}
+		values := byMetric[metric]
+		if len(values) == f.window && math.Abs(v-median(values)) > threshold {
+			spikes = append(spikes, metric)
+		}
+		values = append(values, v)
+		if len(values) > f.window {
+			values = values[1:]
+		}
+		byMetric[metric] = values
+	}
+	sort.Strings(spikes)
+	return spikes
+}
+
+func (f *spikeFilter) delete(device string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.recent, device)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/sink"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statsdCounterLoop periodically forwards the increase of all operational counters to StatsD, so
+// the service can be monitored without scraping /metrics.
+func (m *MeasureServer) statsdCounterLoop(s *sink.StatsD, interval time.Duration) {
+	last := map[string]float64{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		families, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			m.Logger.Warnf("unable to gather counters for StatsD: %s", err)
+			continue
+		}
+		for _, f := range families {
+			if f.GetType() != dto.MetricType_COUNTER || !strings.HasPrefix(f.GetName(), "measure_") {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(f.GetName(), "measure_"), "_total")
+			for _, metric := range f.GetMetric() {
+				tags := map[string]string{}
+				key := f.GetName()
+				for _, l := range metric.GetLabel() {
+					tags[l.GetName()] = l.GetValue()
+					key += "," + l.GetName() + "=" + l.GetValue()
+				}
+				v := metric.GetCounter().GetValue()
+				if delta := v - last[key]; delta > 0 {
+					s.Count(name, delta, tags)
+				}
+				last[key] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltDevicesBucket = []byte("devices")
+
+// Bolt is an embedded store keeping the history of every device in its own bucket keyed by the
+// receive time.
+type Bolt struct {
+	db *bolt.DB
+}
+
+func NewBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open bolt database %q: %s", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltDevicesBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+func boltKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+func (b *Bolt) Save(r Record) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(boltDevicesBucket).CreateBucketIfNotExists([]byte(r.Device))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltKey(r.Received), v)
+	})
+}
+
+func (b *Bolt) Latest() ([]Record, error) {
+	var records []Record
+	err := b.db.View(func(tx *bolt.Tx) error {
+		devices := tx.Bucket(boltDevicesBucket)
+		return devices.ForEachBucket(func(device []byte) error {
+			_, v := devices.Bucket(device).Cursor().Last()
+			if v == nil {
+				return nil
+			}
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			records = append(records, r)
+			return nil
+		})
+	})
+	return records, err
+}
+
+func (b *Bolt) Range(device string, from, to time.Time) ([]Record, error) {
+	records := []Record{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDevicesBucket).Bucket([]byte(device))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		k, v := c.First()
+		if !from.IsZero() {
+			k, v = c.Seek(boltKey(from))
+		}
+		for ; k != nil; k, v = c.Next() {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if !to.IsZero() && r.Received.After(to) {
+				break
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (b *Bolt) Last(device string, n int) ([]Record, error) {
+	records := []Record{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDevicesBucket).Bucket([]byte(device))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil && len(records) < n; k, v = c.Prev() {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	slices.Reverse(records)
+	return records, err
+}
+
+func (b *Bolt) DeleteRange(device string, from, to time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDevicesBucket).Bucket([]byte(device))
+		if bucket == nil {
+			return nil
+		}
+		// Deleting while iterating with a cursor skips keys, so collect them first.
+		var keys [][]byte
+		c := bucket.Cursor()
+		k, _ := c.First()
+		if !from.IsZero() {
+			k, _ = c.Seek(boltKey(from))
+		}
+		for ; k != nil; k, _ = c.Next() {
+			if !to.IsZero() && int64(binary.BigEndian.Uint64(k)) > to.UnixNano() {
+				break
+			}
+			keys = append(keys, append([]byte{}, k...))
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
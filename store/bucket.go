@@ -0,0 +1,84 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/finfinack/measure/data"
+)
+
+type bucketKey struct {
+	device string
+	metric string
+	start  time.Time
+}
+
+// bucket accumulates the values of a metric within a time window.
+type bucket struct {
+	sum, min, max float64
+	count         int
+}
+
+func (b *bucket) add(v float64) {
+	b.sum += v
+	b.count++
+	if v < b.min {
+		b.min = v
+	}
+	if v > b.max {
+		b.max = v
+	}
+}
+
+// metricRows collects rows holding one metric each into records, e.g. of the Postgres readings
+// table.
+type metricRows struct {
+	device string
+	times  []time.Time
+	// metrics is keyed by the Unix nanoseconds of the times as scanned times may differ in
+	// location.
+	metrics map[int64]map[string]float64
+}
+
+func newMetricRows(device string) *metricRows {
+	return &metricRows{device: device, metrics: map[int64]map[string]float64{}}
+}
+
+func (m *metricRows) add(t time.Time, metric string, value float64) {
+	metrics, ok := m.metrics[t.UnixNano()]
+	if !ok {
+		metrics = map[string]float64{}
+		m.metrics[t.UnixNano()] = metrics
+		m.times = append(m.times, t)
+	}
+	metrics[metric] = value
+}
+
+// records returns a record per time in the order the times were first added.
+func (m *metricRows) records() ([]Record, error) {
+	records := make([]Record, 0, len(m.times))
+	for _, t := range m.times {
+		status := data.ReportStatus{Device: m.device}
+		for metric, value := range m.metrics[t.UnixNano()] {
+			status.Set(metric, value)
+		}
+		payload, err := json.Marshal(status)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, NewRecord(m.device, t, payload))
+	}
+	return records, nil
+}
+
+// withDownsampled merges the readings rebuilt from downsampled buckets into records, oldest
+// first.
+func withDownsampled(records, downsampled []Record) []Record {
+	if len(downsampled) == 0 {
+		return records
+	}
+	records = append(downsampled, records...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Received.Before(records[j].Received) })
+	return records
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+)
+
+// WriteCSV writes one row per record with a column for every metric present in any record.
+// Metrics missing in a record are left empty.
+func WriteCSV(w io.Writer, records []Record) error {
+	metrics, rows := tabulate(records)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"device", "timestamp"}, metrics...)); err != nil {
+		return err
+	}
+	for i, r := range records {
+		row := []string{r.Device, r.Received.UTC().Format(time.RFC3339)}
+		for _, name := range metrics {
+			v, ok := rows[i][name]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tabulate extracts the metrics of all records and returns their sorted names together with
+// the metrics of each record.
+func tabulate(records []Record) ([]string, []map[string]float64) {
+	rows := make([]map[string]float64, len(records))
+	present := map[string]bool{}
+	for i, r := range records {
+		rows[i] = r.Reading.Metrics()
+		for name := range rows[i] {
+			present[name] = true
+		}
+	}
+	metrics := make([]string, 0, len(present))
+	for name := range present {
+		metrics = append(metrics, name)
+	}
+	sort.Strings(metrics)
+	return metrics, rows
+}
+
+// ReadCSV parses rows of device, timestamp and metrics such as temperature and humidity into
+// records. The first row must be a header naming the columns; "temp" and "hum" are accepted as
+// aliases and columns which are no valid metric names are ignored. Timestamps are either RFC3339
+// or Unix seconds.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read header: %s", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "temp":
+			name = data.MetricTemperature
+		case "hum":
+			name = data.MetricHumidity
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"device", "timestamp"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []Record
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ts, err := parseTimestamp(field(row, "timestamp"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		status := data.ReportStatus{Device: field(row, "device")}
+		if status.Device == "" {
+			return nil, fmt.Errorf("line %d: device not set", line)
+		}
+		for metric := range columns {
+			if metric == "device" || metric == "timestamp" || !data.ValidMetric(metric) {
+				continue
+			}
+			raw := field(row, metric)
+			if raw == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", line, metric, raw)
+			}
+			status.Set(metric, v)
+		}
+		payload, err := json.Marshal(status)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, NewRecord(status.Device, ts, payload))
+	}
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMilli(int64(secs * 1000)), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
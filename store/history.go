@@ -0,0 +1,71 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// History keeps the most recent readings of every device in memory using a fixed size ring
+// buffer per device.
+type History struct {
+	depth int
+
+	mu       sync.RWMutex
+	readings map[string]*ring
+}
+
+// NewHistory returns a History keeping at most depth readings per device.
+func NewHistory(depth int) *History {
+	return &History{
+		depth:    depth,
+		readings: map[string]*ring{},
+	}
+}
+
+// Add adds a reading to the history of its device, dropping the oldest reading once the
+// configured depth is exceeded. Readings are kept ordered by their receive time.
+func (h *History) Add(r Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buf, ok := h.readings[r.Device]
+	if !ok {
+		buf = newRing(h.depth)
+		h.readings[r.Device] = buf
+	}
+	buf.add(r)
+}
+
+// Devices returns the IDs of all devices with history, sorted.
+func (h *History) Devices() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	devices := make([]string, 0, len(h.readings))
+	for device := range h.readings {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// Delete removes the history of a device.
+func (h *History) Delete(device string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.readings, device)
+}
+
+// Range returns the readings of a device received in [from, to], oldest first. Zero values for
+// from or to leave the respective side of the range open.
+func (h *History) Range(device string, from, to time.Time) []Record {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	buf, ok := h.readings[device]
+	if !ok {
+		return []Record{}
+	}
+	return buf.rangeOf(from, to)
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRow is a single metric value. A long format is used so the schema does not depend on
+// the metrics reported by devices.
+type parquetRow struct {
+	Device    string    `parquet:"device,dict"`
+	Timestamp time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Metric    string    `parquet:"metric,dict"`
+	Value     float64   `parquet:"value"`
+}
+
+// WriteParquet writes one row per record and metric as a Parquet file.
+func WriteParquet(w io.Writer, records []Record) error {
+	pw := parquet.NewGenericWriter[parquetRow](w)
+	for _, r := range records {
+		metrics := r.Reading.Metrics()
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		rows := make([]parquetRow, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, parquetRow{
+				Device:    r.Device,
+				Timestamp: r.Received.UTC(),
+				Metric:    name,
+				Value:     metrics[name],
+			})
+		}
+		if _, err := pw.Write(rows); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
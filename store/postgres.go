@@ -0,0 +1,290 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	_ "github.com/lib/pq"
+)
+
+// Readings are stored one row per metric which keeps the schema stable when devices report
+// additional metrics and allows the table to be turned into a TimescaleDB hypertable on ts.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS readings (
+	ts     TIMESTAMPTZ      NOT NULL,
+	device TEXT             NOT NULL,
+	metric TEXT             NOT NULL,
+	value  DOUBLE PRECISION NOT NULL
+);
+CREATE INDEX IF NOT EXISTS readings_device_metric_ts ON readings (device, metric, ts DESC);
+CREATE TABLE IF NOT EXISTS readings_downsampled (
+	device TEXT             NOT NULL,
+	metric TEXT             NOT NULL,
+	bucket TIMESTAMPTZ      NOT NULL,
+	avg    DOUBLE PRECISION NOT NULL,
+	min    DOUBLE PRECISION NOT NULL,
+	max    DOUBLE PRECISION NOT NULL,
+	count  BIGINT           NOT NULL,
+	PRIMARY KEY (device, metric, bucket)
+);
+CREATE TABLE IF NOT EXISTS latest (
+	device  TEXT        PRIMARY KEY,
+	ts      TIMESTAMPTZ NOT NULL,
+	payload JSONB       NOT NULL
+);
+`
+
+const timescaleHypertable = `SELECT create_hypertable('readings', 'ts', if_not_exists => TRUE, migrate_data => TRUE)`
+
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres connects to the database referenced by dsn and creates the schema if needed.
+// If hypertable is set, the readings table is converted to a TimescaleDB hypertable which
+// requires the timescaledb extension to be installed.
+func NewPostgres(dsn string, hypertable bool) (*Postgres, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open postgres database: %s", err)
+	}
+	if _, err := db.Exec(postgresSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create postgres schema: %s", err)
+	}
+	if hypertable {
+		if _, err := db.Exec(timescaleHypertable); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to create hypertable: %s", err)
+		}
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) Save(r Record) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for metric, value := range r.Reading.Metrics() {
+		if _, err := tx.Exec(
+			"INSERT INTO readings (ts, device, metric, value) VALUES ($1, $2, $3, $4)",
+			r.Received, r.Device, metric, value,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO latest (device, ts, payload) VALUES ($1, $2, $3)
+		ON CONFLICT (device) DO UPDATE SET ts = EXCLUDED.ts, payload = EXCLUDED.payload
+		WHERE latest.ts <= EXCLUDED.ts`,
+		r.Device, r.Received, string(r.Payload),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) Latest() ([]Record, error) {
+	rows, err := p.db.Query("SELECT device, ts, payload FROM latest")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var (
+			r       Record
+			payload string
+		)
+		if err := rows.Scan(&r.Device, &r.Received, &payload); err != nil {
+			return nil, err
+		}
+		r.Payload = []byte(payload)
+		r.Reading = data.ParseReading(r.Payload)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Range returns the readings of a device, rebuilt from the rows of their metrics.
+func (p *Postgres) Range(device string, from, to time.Time) ([]Record, error) {
+	query := "SELECT ts, metric, value FROM readings WHERE device = $1"
+	args := []any{device}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND ts >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND ts <= $%d", len(args))
+	}
+	rows, err := p.db.Query(query+" ORDER BY ts", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := newMetricRows(device)
+	for rows.Next() {
+		var (
+			ts     time.Time
+			metric string
+			value  float64
+		)
+		if err := rows.Scan(&ts, &metric, &value); err != nil {
+			return nil, err
+		}
+		readings.add(ts, metric, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	records, err := readings.records()
+	if err != nil {
+		return nil, err
+	}
+	downsampled, err := p.downsampled(device, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return withDownsampled(records, downsampled), nil
+}
+
+// Last returns the n most recent readings of a device, rebuilt from the rows of their metrics.
+func (p *Postgres) Last(device string, n int) ([]Record, error) {
+	rows, err := p.db.Query(`
+		SELECT ts, metric, value FROM readings
+		WHERE device = $1 AND ts IN (SELECT DISTINCT ts FROM readings WHERE device = $1 ORDER BY ts DESC LIMIT $2)
+		ORDER BY ts`,
+		device, n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := newMetricRows(device)
+	for rows.Next() {
+		var (
+			ts     time.Time
+			metric string
+			value  float64
+		)
+		if err := rows.Scan(&ts, &metric, &value); err != nil {
+			return nil, err
+		}
+		readings.add(ts, metric, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return readings.records()
+}
+
+// downsampled returns the readings rebuilt from the buckets of a device starting in [from, to].
+func (p *Postgres) downsampled(device string, from, to time.Time) ([]Record, error) {
+	query := "SELECT bucket, metric, avg FROM readings_downsampled WHERE device = $1"
+	args := []any{device}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND bucket >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND bucket <= $%d", len(args))
+	}
+	rows, err := p.db.Query(query+" ORDER BY bucket", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := newMetricRows(device)
+	for rows.Next() {
+		var (
+			start  time.Time
+			metric string
+			avg    float64
+		)
+		if err := rows.Scan(&start, &metric, &avg); err != nil {
+			return nil, err
+		}
+		buckets.add(start, metric, avg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets.records()
+}
+
+// DeleteRange deletes the readings and buckets of a device as well as its latest reading if it
+// is in the range.
+func (p *Postgres) DeleteRange(device string, from, to time.Time) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for table, column := range map[string]string{"readings": "ts", "latest": "ts", "readings_downsampled": "bucket"} {
+		query := "DELETE FROM " + table + " WHERE device = $1"
+		args := []any{device}
+		if !from.IsZero() {
+			args = append(args, from)
+			query += fmt.Sprintf(" AND %s >= $%d", column, len(args))
+		}
+		if !to.IsZero() {
+			args = append(args, to)
+			query += fmt.Sprintf(" AND %s <= $%d", column, len(args))
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Compact downsamples raw readings older than the raw retention and deletes expired buckets.
+func (p *Postgres) Compact(ret Retention, now time.Time) error {
+	cutoff := now.Add(-ret.Raw).Truncate(ret.Window)
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO readings_downsampled (device, metric, bucket, avg, min, max, count)
+		SELECT device, metric, to_timestamp(floor(extract(epoch FROM ts) / $2) * $2) AS b, avg(value), min(value), max(value), count(*)
+		FROM readings WHERE ts < $1 GROUP BY device, metric, b
+		ON CONFLICT (device, metric, bucket) DO UPDATE SET
+			avg   = (readings_downsampled.avg * readings_downsampled.count + EXCLUDED.avg * EXCLUDED.count) / (readings_downsampled.count + EXCLUDED.count),
+			min   = LEAST(readings_downsampled.min, EXCLUDED.min),
+			max   = GREATEST(readings_downsampled.max, EXCLUDED.max),
+			count = readings_downsampled.count + EXCLUDED.count`,
+		cutoff, ret.Window.Seconds(),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM readings WHERE ts < $1", cutoff); err != nil {
+		return err
+	}
+	if ret.Downsampled > 0 {
+		if _, err := tx.Exec("DELETE FROM readings_downsampled WHERE bucket < $1", now.Add(-ret.Downsampled)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// ring is a fixed size buffer of readings ordered by receive time which overwrites the oldest
+// reading once full.
+type ring struct {
+	buf   []Record
+	start int
+	n     int
+}
+
+func newRing(size int) *ring {
+	return &ring{buf: make([]Record, size)}
+}
+
+// at returns the i-th oldest reading.
+func (r *ring) at(i int) Record {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+func (r *ring) set(i int, rec Record) {
+	r.buf[(r.start+i)%len(r.buf)] = rec
+}
+
+// search returns the index of the first reading received after t.
+func (r *ring) search(t time.Time) int {
+	return sort.Search(r.n, func(i int) bool { return r.at(i).Received.After(t) })
+}
+
+func (r *ring) add(rec Record) {
+	if len(r.buf) == 0 {
+		return
+	}
+	// Readings usually arrive in order and can simply be appended.
+	i := r.n
+	if r.n > 0 && rec.Received.Before(r.at(r.n-1).Received) {
+		i = r.search(rec.Received)
+	}
+	if i > 0 && r.at(i-1).Received.Equal(rec.Received) {
+		// Already known, e.g. when restoring a backup.
+		return
+	}
+	if r.n == len(r.buf) {
+		if i == 0 {
+			// Older than everything in a full buffer.
+			return
+		}
+		// Drop the oldest reading.
+		r.start = (r.start + 1) % len(r.buf)
+		r.n--
+		i--
+	}
+	for j := r.n; j > i; j-- {
+		r.set(j, r.at(j-1))
+	}
+	r.set(i, rec)
+	r.n++
+}
+
+// rangeOf returns the readings received in [from, to].
+func (r *ring) rangeOf(from, to time.Time) []Record {
+	lo := 0
+	if !from.IsZero() {
+		lo = r.search(from.Add(-time.Nanosecond))
+	}
+	hi := r.n
+	if !to.IsZero() {
+		hi = r.search(to)
+	}
+	records := make([]Record, 0, max(hi-lo, 0))
+	for i := lo; i < hi; i++ {
+		records = append(records, r.at(i))
+	}
+	return records
+}
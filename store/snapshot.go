@@ -0,0 +1,51 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes a file by writing to a temporary file in the same directory first and
+// renaming it to path, so readers never see a partially written file.
+func WriteFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WriteSnapshot atomically writes the given records to path as JSON.
+func WriteSnapshot(path string, records []Record) error {
+	return WriteFileAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(records)
+	})
+}
+
+// ReadSnapshot reads records previously written with WriteSnapshot. A missing file is not an
+// error and results in no records.
+func ReadSnapshot(path string) ([]Record, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
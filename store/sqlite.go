@@ -0,0 +1,289 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS readings (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	device   TEXT    NOT NULL,
+	received INTEGER NOT NULL,
+	payload  TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS readings_device_received ON readings (device, received);
+CREATE TABLE IF NOT EXISTS downsampled (
+	device TEXT    NOT NULL,
+	metric TEXT    NOT NULL,
+	bucket INTEGER NOT NULL,
+	avg    REAL    NOT NULL,
+	min    REAL    NOT NULL,
+	max    REAL    NOT NULL,
+	count  INTEGER NOT NULL,
+	PRIMARY KEY (device, metric, bucket)
+);
+`
+
+// sqliteLatestIDs selects the ID of the most recently received reading of every device.
+const sqliteLatestIDs = `
+	SELECT (SELECT id FROM readings i WHERE i.device = o.device ORDER BY received DESC, id DESC LIMIT 1)
+	FROM readings o GROUP BY device`
+
+type SQLite struct {
+	db *sql.DB
+}
+
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database %q: %s", path, err)
+	}
+	// SQLite does not support concurrent writers.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create sqlite schema: %s", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Save(r Record) error {
+	_, err := s.db.Exec(
+		"INSERT INTO readings (device, received, payload) VALUES (?, ?, ?)",
+		r.Device, r.Received.UnixMilli(), string(r.Payload),
+	)
+	return err
+}
+
+func (s *SQLite) Latest() ([]Record, error) {
+	rows, err := s.db.Query(`
+		SELECT device, received, payload FROM readings
+		WHERE id IN (` + sqliteLatestIDs + `)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var (
+			r        Record
+			received int64
+			payload  string
+		)
+		if err := rows.Scan(&r.Device, &received, &payload); err != nil {
+			return nil, err
+		}
+		r.Received = time.UnixMilli(received)
+		r.Payload = []byte(payload)
+		r.Reading = data.ParseReading(r.Payload)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLite) Range(device string, from, to time.Time) ([]Record, error) {
+	query := "SELECT received, payload FROM readings WHERE device = ?"
+	args := []any{device}
+	if !from.IsZero() {
+		query += " AND received >= ?"
+		args = append(args, from.UnixMilli())
+	}
+	if !to.IsZero() {
+		query += " AND received <= ?"
+		args = append(args, to.UnixMilli())
+	}
+	rows, err := s.db.Query(query+" ORDER BY received, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var (
+			received int64
+			payload  string
+		)
+		if err := rows.Scan(&received, &payload); err != nil {
+			return nil, err
+		}
+		records = append(records, NewRecord(device, time.UnixMilli(received), []byte(payload)))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	downsampled, err := s.downsampled(device, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return withDownsampled(records, downsampled), nil
+}
+
+func (s *SQLite) Last(device string, n int) ([]Record, error) {
+	rows, err := s.db.Query(`
+		SELECT received, payload FROM (
+			SELECT id, received, payload FROM readings WHERE device = ? ORDER BY received DESC, id DESC LIMIT ?
+		) ORDER BY received, id`,
+		device, n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var (
+			received int64
+			payload  string
+		)
+		if err := rows.Scan(&received, &payload); err != nil {
+			return nil, err
+		}
+		records = append(records, NewRecord(device, time.UnixMilli(received), []byte(payload)))
+	}
+	return records, rows.Err()
+}
+
+// downsampled returns the readings rebuilt from the buckets of a device starting in [from, to].
+func (s *SQLite) downsampled(device string, from, to time.Time) ([]Record, error) {
+	query := "SELECT bucket, metric, avg FROM downsampled WHERE device = ?"
+	args := []any{device}
+	if !from.IsZero() {
+		query += " AND bucket >= ?"
+		args = append(args, from.UnixMilli())
+	}
+	if !to.IsZero() {
+		query += " AND bucket <= ?"
+		args = append(args, to.UnixMilli())
+	}
+	rows, err := s.db.Query(query+" ORDER BY bucket", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := newMetricRows(device)
+	for rows.Next() {
+		var (
+			start  int64
+			metric string
+			avg    float64
+		)
+		if err := rows.Scan(&start, &metric, &avg); err != nil {
+			return nil, err
+		}
+		buckets.add(time.UnixMilli(start), metric, avg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets.records()
+}
+
+func (s *SQLite) DeleteRange(device string, from, to time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for table, column := range map[string]string{"readings": "received", "downsampled": "bucket"} {
+		query := "DELETE FROM " + table + " WHERE device = ?"
+		args := []any{device}
+		if !from.IsZero() {
+			query += " AND " + column + " >= ?"
+			args = append(args, from.UnixMilli())
+		}
+		if !to.IsZero() {
+			query += " AND " + column + " <= ?"
+			args = append(args, to.UnixMilli())
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Compact downsamples raw readings older than the raw retention and deletes expired buckets.
+// The latest reading of every device is always kept so it can be restored on startup.
+func (s *SQLite) Compact(ret Retention, now time.Time) error {
+	cutoff := now.Add(-ret.Raw).Truncate(ret.Window)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT device, received, payload FROM readings
+		WHERE received < ? AND id NOT IN (`+sqliteLatestIDs+`)`,
+		cutoff.UnixMilli(),
+	)
+	if err != nil {
+		return err
+	}
+	aggs := map[bucketKey]*bucket{}
+	for rows.Next() {
+		var (
+			device   string
+			received int64
+			payload  string
+		)
+		if err := rows.Scan(&device, &received, &payload); err != nil {
+			rows.Close()
+			return err
+		}
+		start := time.UnixMilli(received).Truncate(ret.Window)
+		for metric, value := range data.ExtractMetrics([]byte(payload)) {
+			k := bucketKey{device: device, metric: metric, start: start}
+			if _, ok := aggs[k]; !ok {
+				aggs[k] = &bucket{min: value, max: value}
+			}
+			aggs[k].add(value)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for k, b := range aggs {
+		if _, err := tx.Exec(`
+			INSERT INTO downsampled (device, metric, bucket, avg, min, max, count) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (device, metric, bucket) DO UPDATE SET
+				avg   = (downsampled.avg * downsampled.count + excluded.avg * excluded.count) / (downsampled.count + excluded.count),
+				min   = MIN(downsampled.min, excluded.min),
+				max   = MAX(downsampled.max, excluded.max),
+				count = downsampled.count + excluded.count`,
+			k.device, k.metric, k.start.UnixMilli(), b.sum/float64(b.count), b.min, b.max, b.count,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		"DELETE FROM readings WHERE received < ? AND id NOT IN ("+sqliteLatestIDs+")",
+		cutoff.UnixMilli(),
+	); err != nil {
+		return err
+	}
+	if ret.Downsampled > 0 {
+		if _, err := tx.Exec("DELETE FROM downsampled WHERE bucket < ?", now.Add(-ret.Downsampled).UnixMilli()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/finfinack/measure/data"
+)
+
+// Record is a single reading as received from a device.
+type Record struct {
+	Device   string          `json:"device"`
+	Received time.Time       `json:"received"`
+	Payload  json.RawMessage `json:"payload"`
+	// Reading is the normalized form of Payload. It is not persisted but derived from the
+	// payload whenever a record is created or decoded.
+	Reading data.Reading `json:"-"`
+}
+
+// NewRecord returns a record of a payload received from a device.
+func NewRecord(device string, received time.Time, payload json.RawMessage) Record {
+	return Record{Device: device, Received: received, Payload: payload, Reading: data.ParseReading(payload)}
+}
+
+func (r *Record) UnmarshalJSON(b []byte) error {
+	type record Record
+	if err := json.Unmarshal(b, (*record)(r)); err != nil {
+		return err
+	}
+	r.Reading = data.ParseReading(r.Payload)
+	return nil
+}
+
+// Store persists readings so that device state survives restarts.
+type Store interface {
+	// Save persists a single reading.
+	Save(r Record) error
+	// Latest returns the most recent reading of every known device.
+	Latest() ([]Record, error)
+	Close() error
+}
+
+// Retention configures how long a store keeps readings.
+type Retention struct {
+	// Raw is the age after which raw readings are downsampled into buckets of size Window.
+	Raw    time.Duration
+	Window time.Duration
+	// Downsampled is the age after which downsampled buckets are deleted. Zero keeps them forever.
+	Downsampled time.Duration
+}
+
+// Compactor is implemented by stores which support downsampling and expiring old readings.
+type Compactor interface {
+	Compact(ret Retention, now time.Time) error
+}
+
+// Ranger is implemented by stores which can return the history of a device.
+type Ranger interface {
+	// Range returns the readings of a device received in [from, to], oldest first. Zero values
+	// for from or to leave the respective side of the range open. Readings downsampled by
+	// Compact are returned as one reading per bucket with the averages, received at its start.
+	Range(device string, from, to time.Time) ([]Record, error)
+}
+
+// Laster is implemented by stores which can return the most recent readings of a device without
+// loading its whole history.
+type Laster interface {
+	// Last returns the n most recently received readings of a device, oldest first. Readings
+	// downsampled by Compact are not returned.
+	Last(device string, n int) ([]Record, error)
+}
+
+// Deleter is implemented by stores which can delete the history of a device.
+type Deleter interface {
+	// DeleteRange deletes the readings of a device received in [from, to]. Zero values for from
+	// or to leave the respective side of the range open. Buckets downsampled by Compact starting
+	// in the range are deleted as well.
+	DeleteRange(device string, from, to time.Time) error
+}
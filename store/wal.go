@@ -0,0 +1,139 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// WAL is an append-only log of all readings stored as JSON lines. Once the log grows beyond
+// maxSize it is rotated to path.1, path.2, ... keeping at most maxFiles rotated files.
+type WAL struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewWAL(path string, maxSize int64, maxFiles int) (*WAL, error) {
+	w := &WAL{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAL) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open write-ahead log %q: %s", w.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+func (w *WAL) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	for i := w.maxFiles - 1; i > 0; i-- {
+		err := os.Rename(rotatedName(w.path, i), rotatedName(w.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if w.maxFiles > 0 {
+		if err := os.Rename(w.path, rotatedName(w.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Append writes a record to the log and syncs it to disk.
+func (w *WAL) Append(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(b)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("unable to rotate write-ahead log: %s", err)
+		}
+	}
+	n, err := w.file.Write(b)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// ReplayWAL calls fn for every record in the log at path including all rotated files, oldest first.
+func ReplayWAL(path string, maxFiles int, fn func(Record)) error {
+	for i := maxFiles; i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = rotatedName(path, i)
+		}
+		if err := replayFile(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replayFile(name string, fn func(Record)) error {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A partially written last line is expected after a crash.
+			continue
+		}
+		fn(r)
+	}
+	return scanner.Err()
+}
+
+func rotatedName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Readings"
+
+// WriteXLSX writes the records as a spreadsheet with the same layout as WriteCSV. Metrics are
+// written as numbers and timestamps as dates so they can be charted right away.
+func WriteXLSX(w io.Writer, records []Record) error {
+	metrics, rows := tabulate(records)
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName(f.GetSheetName(0), xlsxSheet); err != nil {
+		return err
+	}
+	sw, err := f.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return err
+	}
+	dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 22}) // m/d/yy h:mm
+	if err != nil {
+		return err
+	}
+
+	header := []interface{}{"device", "timestamp"}
+	for _, name := range metrics {
+		header = append(header, name)
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+	for i, r := range records {
+		row := []interface{}{r.Device, excelize.Cell{StyleID: dateStyle, Value: r.Received.UTC()}}
+		for _, name := range metrics {
+			v, ok := rows[i][name]
+			if !ok {
+				row = append(row, nil)
+				continue
+			}
+			row = append(row, v)
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	_, err = f.WriteTo(w)
+	return err
+}
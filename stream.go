@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive is the interval comments are sent at on idle streams so proxies don't close
+// them.
+const streamKeepAlive = 30 * time.Second
+
+// liveReading is a reading pushed to streaming consumers. Metrics saves clients from parsing the
+// different payloads devices send.
+type liveReading struct {
+	Device   string             `json:"device"`
+	Received time.Time          `json:"received"`
+	Payload  json.RawMessage    `json:"payload"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+func newLiveReading(r store.Record, unit string) liveReading {
+	r = outputRecord(r, unit)
+	return liveReading{Device: r.Device, Received: r.Received, Payload: r.Payload, Metrics: r.Reading.Metrics()}
+}
+
+// streamHandler streams every new reading as server-sent event named reading, optionally only
+// those of the devices given as ?device= or having all tags given as ?tag=<key>:<value>.
+// Temperatures are sent in unit.
+func (m *MeasureServer) streamHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Devices []string `form:"device"`
+		Tags    []string `form:"tag"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	tags, err := parseTagFilter(parsedQueryParameters.Tags)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	unit, err := requestUnit(ctx)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	devices := make([]string, len(parsedQueryParameters.Devices))
+	for i, d := range parsedQueryParameters.Devices {
+		devices[i] = m.ids.canonical(d)
+	}
+
+	readings, unsubscribe := m.hub.subscribe()
+	defer unsubscribe()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case r, ok := <-readings:
+			if !ok {
+				return false
+			}
+			if len(devices) > 0 && !slices.Contains(devices, r.Device) {
+				return true
+			}
+			if !m.registry.get(r.Device).matches(tags) {
+				return true
+			}
+			ctx.SSEvent("reading", newLiveReading(r, unit))
+			return true
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/sink"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const subscribeTimeout = 10 * time.Second
+
+// subscribeMQTT subscribes to the given topic filters and hands received messages to their
+// handler. Topics are subscribed again after every reconnect.
+func (m *MeasureServer) subscribeMQTT(cfg sink.MQTTConfig, subscriptions map[string]mqtt.MessageHandler) (mqtt.Client, error) {
+	cfg.ClientID += "-subscriber"
+	cfg.OnConnect = func(c mqtt.Client) {
+		for topic, handler := range subscriptions {
+			token := c.Subscribe(topic, cfg.QoS, handler)
+			go func() {
+				if token.WaitTimeout(subscribeTimeout) && token.Error() != nil {
+					m.Logger.Warnf("unable to subscribe to %q: %s", topic, token.Error())
+				}
+			}()
+		}
+	}
+	return sink.NewMQTTClient(cfg, m.Logger)
+}
+
+// mqttHandler receives the RPC notifications Shelly Gen2 devices publish over MQTT (when "RPC
+// status notifications" are enabled) and records them like messages received via websocket.
+func (m *MeasureServer) mqttHandler(_ mqtt.Client, message mqtt.Message) {
+	payload := message.Payload()
+	m.Logger.Debugf("recv on %q: %s", message.Topic(), payload)
+	var msg data.WSMessage
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Method == "" {
+		// Devices also publish plain values like their online state on neighbouring topics.
+		m.Logger.Debugf("ignoring non-RPC message on %q", message.Topic())
+		return
+	}
+	mqttMessages.WithLabelValues(msg.Method).Inc()
+
+	ctx, span := tracer.Start(context.Background(), "mqtt "+msg.Method, trace.WithAttributes(
+		attribute.String("device", msg.Src),
+		attribute.String("method", msg.Method),
+		attribute.String("topic", message.Topic()),
+	))
+	defer span.End()
+	m.notify(ctx, msg, payload)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tabularFormats maps the formats supported by writeTabular onto their writer, content type and
+// file extension.
+var tabularFormats = map[string]struct {
+	write       func(io.Writer, []store.Record) error
+	contentType string
+	extension   string
+}{
+	"csv":  {store.WriteCSV, "text/csv", "csv"},
+	"xlsx": {store.WriteXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+}
+
+// tabularFormat returns the spreadsheet format requested either explicitly or via the Accept
+// header, or "" if JSON should be returned.
+func tabularFormat(ctx *gin.Context, format string) (string, error) {
+	switch format {
+	case "":
+		switch ctx.NegotiateFormat(gin.MIMEJSON, tabularFormats["csv"].contentType, tabularFormats["xlsx"].contentType) {
+		case tabularFormats["csv"].contentType:
+			return "csv", nil
+		case tabularFormats["xlsx"].contentType:
+			return "xlsx", nil
+		}
+		return "", nil
+	case "json":
+		return "", nil
+	}
+	if _, ok := tabularFormats[format]; !ok {
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+	return format, nil
+}
+
+// writeTabular responds with the records in the given spreadsheet format.
+func (m *MeasureServer) writeTabular(ctx *gin.Context, format, name string, records []store.Record) {
+	f := tabularFormats[format]
+	ctx.Header("Content-Type", f.contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, f.extension))
+	ctx.Status(http.StatusOK)
+	if err := f.write(ctx.Writer, records); err != nil {
+		m.Logger.Warnf("unable to write %s: %s", format, err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenLength is the number of random bytes of a device token.
+const tokenLength = 32
+
+// hashToken returns the hash of a device token as kept in the device metadata.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// issueTokenHandler issues a new write token to a device, replacing any previous one. Only the
+// hash of the token is kept, so it is returned just once. Devices present it like an API key
+// on the report endpoints or when connecting via websocket, e.g. ws://host/measure/v1/ws?apikey=<token>.
+func (m *MeasureServer) issueTokenHandler(ctx *gin.Context) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	token := hex.EncodeToString(b)
+	device := m.ids.canonical(ctx.Param("id"))
+	if err := m.registry.update(device, func(d *deviceMeta) { d.TokenHash = hashToken(token) }); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	m.Logger.Infof("issued token to device %q", device)
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+		"token":  token,
+	})
+}
+
+// revokeTokenHandler revokes the write token of a device. The device is open for reports again
+// unless -writeKey is set.
+func (m *MeasureServer) revokeTokenHandler(ctx *gin.Context) {
+	device := m.ids.canonical(ctx.Param("id"))
+	if err := m.registry.update(device, func(d *deviceMeta) { d.TokenHash = "" }); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	m.Logger.Infof("revoked token of device %q", device)
+	ctx.JSON(http.StatusOK, gin.H{
+		"device": device,
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+)
+
+var (
+	trendWindow     = flag.Duration("trendWindow", 30*time.Minute, "Window of recent readings the trend of every metric is computed over.")
+	trendThresholds = flag.String("trendThresholds", "temperature=0.5,humidity=2", "Comma separated <metric>=<change per hour> pairs below which a metric is reported as steady. Other metrics use 1 per hour.")
+)
+
+const (
+	trendRising  = "rising"
+	trendFalling = "falling"
+	trendSteady  = "steady"
+
+	defaultTrendThreshold = 1.0
+)
+
+// trend is the direction a metric moved in recently. Slope is the change per hour.
+type trend struct {
+	Direction string  `json:"direction"`
+	Slope     float64 `json:"slope"`
+}
+
+// parseThresholds parses comma separated <metric>=<value> pairs of non-negative thresholds.
+func parseThresholds(v string) (map[string]float64, error) {
+	thresholds := map[string]float64{}
+	if v == "" {
+		return thresholds, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		metric, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !data.ValidMetric(metric) {
+			return nil, fmt.Errorf("invalid threshold %q", pair)
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid threshold %q", pair)
+		}
+		thresholds[metric] = f
+	}
+	return thresholds, nil
+}
+
+// trends returns the trend of every metric of a device over the readings of the last
+// -trendWindow in the history. Metrics need at least two readings at different times. Slopes of
+// temperatures are returned in unit.
+func (m *MeasureServer) trends(device string, now time.Time, unit string) map[string]trend {
+	type point struct{ x, y float64 }
+	points := map[string][]point{}
+	for _, r := range m.History.Range(device, now.Add(-*trendWindow), time.Time{}) {
+		x := r.Received.Sub(now).Hours()
+		for metric, v := range r.Reading.Metrics() {
+			points[metric] = append(points[metric], point{x, v})
+		}
+	}
+
+	trends := map[string]trend{}
+	for metric, ps := range points {
+		// Least squares fit of the values over time.
+		var sx, sy, sxx, sxy float64
+		for _, p := range ps {
+			sx += p.x
+			sy += p.y
+			sxx += p.x * p.x
+			sxy += p.x * p.y
+		}
+		n := float64(len(ps))
+		d := n*sxx - sx*sx
+		if len(ps) < 2 || d == 0 {
+			continue
+		}
+		slope := (n*sxy - sx*sy) / d
+
+		threshold, ok := m.trendLimits[metric]
+		if !ok {
+			threshold = defaultTrendThreshold
+		}
+		t := trend{Direction: trendSteady}
+		switch {
+		case slope > threshold:
+			t.Direction = trendRising
+		case slope < -threshold:
+			t.Direction = trendFalling
+		}
+		// Converting a change only scales it, the offset of the units cancels out.
+		t.Slope = math.Round((data.ConvertMetric(metric, slope, unit)-data.ConvertMetric(metric, 0, unit))*100) / 100
+		trends[metric] = t
+	}
+	if len(trends) == 0 {
+		return nil
+	}
+	return trends
+}
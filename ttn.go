@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ttnToken   = flag.String("ttnToken", "", "Bearer token The Things Network webhooks have to send in the Authorization header. Empty accepts all uplinks.")
+	ttnMapping = flag.String("ttnMapping", "temperature=temperature,humidity=humidity,battery=battery", "Comma separated <metric>=<path> pairs locating metrics in the decoded payload of uplinks, e.g. temperature=TempC_SHT.")
+)
+
+// parseTTNMapping parses the metric paths of -ttnMapping.
+func parseTTNMapping(v string) (map[string]data.Path, error) {
+	mapping := map[string]data.Path{}
+	for _, pair := range strings.Split(v, ",") {
+		metric, expr, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mapping %q", pair)
+		}
+		if !data.ValidMetric(metric) {
+			return nil, fmt.Errorf("invalid metric %q", metric)
+		}
+		p, err := data.ParsePath(expr)
+		if err != nil {
+			return nil, err
+		}
+		mapping[metric] = p
+	}
+	return mapping, nil
+}
+
+// ttnHandler accepts uplink messages of The Things Network (v3) webhooks. Metrics are taken from
+// the output of the payload formatter configured for the application or device.
+func (m *MeasureServer) ttnHandler(mapping map[string]data.Path) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var uplink struct {
+			EndDeviceIDs struct {
+				DeviceID string `json:"device_id"`
+			} `json:"end_device_ids"`
+			UplinkMessage *struct {
+				ReceivedAt     time.Time       `json:"received_at"`
+				DecodedPayload json.RawMessage `json:"decoded_payload"`
+			} `json:"uplink_message"`
+		}
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxWriteSize)
+		if err := ctx.ShouldBindJSON(&uplink); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		// Webhooks may be enabled for other messages like joins as well.
+		if uplink.UplinkMessage == nil {
+			ctx.Status(http.StatusNoContent)
+			return
+		}
+		if len(uplink.UplinkMessage.DecodedPayload) == 0 {
+			reportRequests.WithLabelValues("rejected").Inc()
+			abortWithError(ctx, http.StatusBadRequest, errors.New("uplink without decoded payload, is a payload formatter configured?"))
+			return
+		}
+
+		var doc any
+		dec := json.NewDecoder(bytes.NewReader(uplink.UplinkMessage.DecodedPayload))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		r := reportReading{ID: uplink.EndDeviceIDs.DeviceID}
+		if t := uplink.UplinkMessage.ReceivedAt; !t.IsZero() {
+			ts := float64(t.UnixMilli()) / 1000
+			r.Timestamp = &ts
+		}
+		for metric, path := range mapping {
+			v, ok := path.Float(doc)
+			if !ok {
+				continue
+			}
+			r.set(metric, v)
+		}
+		status, err := r.status()
+		if err != nil {
+			reportRequests.WithLabelValues("rejected").Inc()
+			abortWithError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		if !m.authorized(ctx, status.Device) {
+			reportRequests.WithLabelValues("rejected").Inc()
+			abortWithError(ctx, http.StatusForbidden, fmt.Errorf("not authorized to report device %q", status.Device))
+			return
+		}
+		msg, err := json.Marshal(status)
+		if err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		m.recordAt(ctx.Request.Context(), status.Device, json.RawMessage(msg), r.received(time.Now()))
+		reportRequests.WithLabelValues("accepted").Inc()
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/finfinack/measure/data"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxUDPPacket = 1500
+
+var udpAddr = flag.String("udpAddr", "", "Address (e.g. :5514) to receive readings as UDP lines \"<device> <temp> <hum>\" on. Empty disables the UDP listener.")
+
+// listenUDP records readings sent as plain text lines by sensors too constrained for HTTP.
+// Every line is "<device> <temp> <hum>" where "-" marks a missing value. Syslog headers are
+// ignored, so devices may also log their readings to measure.
+func (m *MeasureServer) listenUDP(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, maxUDPPacket)
+		for {
+			n, src, err := conn.ReadFrom(buf)
+			if err != nil {
+				m.Logger.Warnf("unable to read UDP packet: %s", err)
+				return
+			}
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				if err := m.udpLine(line, src); err != nil {
+					reportRequests.WithLabelValues("rejected").Inc()
+					m.Logger.Debugf("ignoring UDP line %q from %s: %s", line, src, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *MeasureServer) udpLine(line string, src net.Addr) error {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return fmt.Errorf("expected <device> <temp> <hum>")
+	}
+	// Syslog wraps the message into a header, so only the last fields are considered.
+	fields = fields[len(fields)-3:]
+	if strings.HasPrefix(fields[0], "<") {
+		if i := strings.Index(fields[0], ">"); i > 0 {
+			fields[0] = fields[0][i+1:]
+		}
+	}
+
+	r := data.ReportStatus{Device: fields[0]}
+	for i, value := range []**float64{&r.Temperature, &r.Humidity} {
+		raw := fields[i+1]
+		if raw == "-" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", raw)
+		}
+		*value = &v
+	}
+	if r.Device == "" || (r.Temperature == nil && r.Humidity == nil) {
+		return fmt.Errorf("not enough values set")
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	ctx, span := tracer.Start(context.Background(), "udp", trace.WithAttributes(
+		attribute.String("device", r.Device),
+		attribute.String("source", src.String()),
+	))
+	defer span.End()
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	m.record(ctx, r.Device, json.RawMessage(msg))
+	reportRequests.WithLabelValues("accepted").Inc()
+	return nil
+}
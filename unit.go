@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/finfinack/measure/data"
+	"github.com/finfinack/measure/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+var temperatureUnit = flag.String("temperatureUnit", "c", "Unit temperatures are returned in by default, c or f. Requests can override it with ?unit=.")
+
+// parseUnit returns the temperature unit of a unit flag or query parameter.
+func parseUnit(unit string) (string, error) {
+	switch strings.ToLower(unit) {
+	case "c", "celsius":
+		return data.UnitCelsius, nil
+	case "f", "fahrenheit":
+		return data.UnitFahrenheit, nil
+	}
+	return "", fmt.Errorf("unsupported unit %q", unit)
+}
+
+// requestUnit returns the temperature unit requested via ?unit= or the server default.
+func requestUnit(ctx *gin.Context) (string, error) {
+	return parseUnit(ctx.DefaultQuery("unit", *temperatureUnit))
+}
+
+// outputRecord prepares a record to be returned in the given unit. Reported values are
+// re-encoded, which also turns values of records persisted by older versions from strings into
+// numbers. Device payloads already carry temperatures in both units and are left as is.
+func outputRecord(r store.Record, unit string) store.Record {
+	r.Reading = r.Reading.InUnit(unit)
+
+	var msg data.WSMessage
+	if err := json.Unmarshal(r.Payload, &msg); err != nil || msg.Method != "" {
+		return r
+	}
+	var status data.ReportStatus
+	if err := json.Unmarshal(r.Payload, &status); err != nil {
+		return r
+	}
+	if payload, err := json.Marshal(status.InUnit(unit)); err == nil {
+		r.Payload = payload
+	}
+	return r
+}
+
+// outputRecords applies outputRecord to all records.
+func outputRecords(records []store.Record, unit string) []store.Record {
+	out := make([]store.Record, len(records))
+	for i, r := range records {
+		out[i] = outputRecord(r, unit)
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/cache"
+	"github.com/finfinack/measure/data"
+)
+
+var virtualDevices stringList
+
+func init() {
+	flag.Var(&virtualDevices, "virtualDevice", "Device computed from the cached readings of others as <id>=<function>(<source>,...), where function is avg, min, max or diff (first minus second source, recorded as <metric>_diff) and a source is a device ID or group:<name>, e.g. house=avg(group:indoor) or delta=diff(outdoor,living-room). Can be repeated.")
+}
+
+// Functions of virtual devices.
+const (
+	virtualAvg  = "avg"
+	virtualMin  = "min"
+	virtualMax  = "max"
+	virtualDiff = "diff"
+)
+
+const groupSource = "group:"
+
+// derivedMetrics are computed from the aggregated temperature and humidity of a virtual device.
+var derivedMetrics = []string{data.MetricDewPoint, data.MetricHeatIndex, data.MetricAbsoluteHumidity, data.MetricVPD}
+
+// virtualDevice is computed from the readings of its sources, which are device IDs or groups.
+type virtualDevice struct {
+	id      string
+	fn      string
+	sources []string
+}
+
+// parseVirtualDevices parses the -virtualDevice flags by device ID.
+func parseVirtualDevices(values []string) (map[string]*virtualDevice, error) {
+	devices := map[string]*virtualDevice{}
+	for _, v := range values {
+		id, expr, ok := strings.Cut(v, "=")
+		fn, args, ok2 := strings.Cut(strings.TrimSpace(expr), "(")
+		args, ok3 := strings.CutSuffix(strings.TrimSpace(args), ")")
+		id = data.CanonicalID(id)
+		if !ok || !ok2 || !ok3 || id == "" {
+			return nil, fmt.Errorf("invalid virtual device %q, expected <id>=<function>(<source>,...)", v)
+		}
+		d := &virtualDevice{id: id, fn: strings.TrimSpace(fn)}
+		for _, s := range strings.Split(args, ",") {
+			if group, ok := strings.CutPrefix(strings.TrimSpace(s), groupSource); ok {
+				d.sources = append(d.sources, groupSource+strings.TrimSpace(group))
+				continue
+			}
+			source := data.CanonicalID(s)
+			if source == "" || source == id {
+				return nil, fmt.Errorf("virtual device %q has invalid source %q", v, s)
+			}
+			d.sources = append(d.sources, source)
+		}
+		switch d.fn {
+		case virtualAvg, virtualMin, virtualMax:
+		case virtualDiff:
+			if len(d.sources) != 2 || strings.HasPrefix(d.sources[0], groupSource) || strings.HasPrefix(d.sources[1], groupSource) {
+				return nil, fmt.Errorf("virtual device %q: diff requires two devices", v)
+			}
+		default:
+			return nil, fmt.Errorf("virtual device %q has unsupported function %q", v, d.fn)
+		}
+		devices[id] = d
+	}
+	return devices, nil
+}
+
+// members returns the devices the virtual device is currently computed from.
+func (d *virtualDevice) members(reg *registry) []string {
+	var devices []string
+	for _, s := range d.sources {
+		group, ok := strings.CutPrefix(s, groupSource)
+		if !ok {
+			devices = append(devices, s)
+			continue
+		}
+		for _, device := range reg.members(group) {
+			if device != d.id && !slices.Contains(devices, device) {
+				devices = append(devices, device)
+			}
+		}
+	}
+	return devices
+}
+
+// updateVirtual recomputes the virtual devices computed from device after it reported. Readings
+// of virtual devices don't trigger updates, so they can't depend on each other in cycles.
+func (m *MeasureServer) updateVirtual(ctx context.Context, device string, received time.Time) {
+	if _, ok := m.virtual[device]; ok {
+		return
+	}
+	for _, v := range m.virtual {
+		members := v.members(m.registry)
+		if !slices.Contains(members, device) {
+			continue
+		}
+		status, ok := m.computeVirtual(v, members)
+		if !ok {
+			continue
+		}
+		payload, err := json.Marshal(status)
+		if err != nil {
+			continue
+		}
+		m.recordCalibrated(ctx, v.id, json.RawMessage(payload), received)
+	}
+}
+
+// computeVirtual applies the function of a virtual device to the cached readings of its members.
+// Metrics are included if any member reports them, or both for diff. Derived metrics such as the
+// dew point are computed from the aggregated values instead, but differ between two devices like
+// any other metric. It returns false if no metric could be computed.
+func (m *MeasureServer) computeVirtual(v *virtualDevice, members []string) (data.ReportStatus, bool) {
+	var readings []map[string]float64
+	for _, device := range members {
+		r, err := m.Cache.Get(device)
+		if errors.Is(err, cache.ErrNotFound) && v.fn != virtualDiff {
+			continue
+		}
+		if err != nil {
+			return data.ReportStatus{}, false
+		}
+		metrics := r.Reading.Metrics()
+		if v.fn != virtualDiff {
+			for _, metric := range derivedMetrics {
+				delete(metrics, metric)
+			}
+		}
+		readings = append(readings, metrics)
+	}
+
+	values := map[string]float64{}
+	if v.fn == virtualDiff {
+		// Differences are no readings of their own, e.g. a negative humidity is fine.
+		for metric, a := range readings[0] {
+			if b, ok := readings[1][metric]; ok {
+				values[metric+data.MetricDiffSuffix] = math.Round((a-b)*100) / 100
+			}
+		}
+	} else {
+		counts := map[string]int{}
+		for _, metrics := range readings {
+			for metric, x := range metrics {
+				prev, ok := values[metric]
+				switch {
+				case !ok:
+					values[metric] = x
+				case v.fn == virtualMin:
+					values[metric] = math.Min(prev, x)
+				case v.fn == virtualMax:
+					values[metric] = math.Max(prev, x)
+				default:
+					// Sum until all readings are seen.
+					values[metric] = prev + x
+				}
+				counts[metric]++
+			}
+		}
+		if v.fn == virtualAvg {
+			for metric, n := range counts {
+				values[metric] = math.Round(values[metric]/float64(n)*100) / 100
+			}
+		}
+	}
+	if len(values) == 0 {
+		return data.ReportStatus{}, false
+	}
+
+	status := data.ReportStatus{Device: v.id}
+	for metric, x := range values {
+		status.Set(metric, x)
+	}
+	return status, true
+}
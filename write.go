@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxWriteSize = 1 << 20
+
+// fieldAliases maps commonly used line protocol field names onto metrics.
+var fieldAliases = map[string]string{
+	"temperature": data.MetricTemperature,
+	"temp":        data.MetricTemperature,
+	"humidity":    data.MetricHumidity,
+	"hum":         data.MetricHumidity,
+	"battery":     data.MetricBattery,
+	"pressure":    data.MetricPressure,
+	"illuminance": data.MetricIlluminance,
+	"lux":         data.MetricIlluminance,
+	"co2":         data.MetricCO2,
+}
+
+// writeHandler accepts InfluxDB line protocol as sent by Telegraf or firmwares speaking it. The
+// device is taken from the device tag, falling back to the host tag Telegraf sets.
+func (m *MeasureServer) writeHandler(ctx *gin.Context) {
+	type queryParameters struct {
+		Precision string `form:"precision"`
+	}
+
+	var parsedQueryParameters queryParameters
+	if err := ctx.ShouldBindQuery(&parsedQueryParameters); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxWriteSize))
+	if err != nil {
+		abortWithError(ctx, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	points, err := data.ParseLineProtocol(body, parsedQueryParameters.Precision)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	// Nothing is recorded unless all points are valid.
+	type reading struct {
+		device   string
+		msg      json.RawMessage
+		received time.Time
+	}
+	var readings []reading
+	now := time.Now()
+	for _, p := range points {
+		device := p.Tags["device"]
+		if device == "" {
+			device = p.Tags["host"]
+		}
+		if device == "" {
+			abortWithError(ctx, http.StatusBadRequest, errors.New("point without device or host tag"))
+			return
+		}
+		r := data.ReportStatus{Device: device}
+		for name, value := range p.Fields {
+			if metric, ok := fieldAliases[strings.ToLower(name)]; ok {
+				r.Set(metric, value)
+			}
+		}
+		if r.Temperature == nil && r.Humidity == nil && r.Battery == nil && len(r.Metrics) == 0 {
+			continue
+		}
+		if err := r.Validate(); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("point of %q: %s", device, err))
+			return
+		}
+		if !m.authorized(ctx, device) {
+			abortWithError(ctx, http.StatusForbidden, fmt.Errorf("not authorized to report device %q", device))
+			return
+		}
+		received := p.Time
+		if received.IsZero() {
+			received = now
+		} else if err := validReceived(received, now); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, fmt.Errorf("point of %q: %s", device, err))
+			return
+		}
+		msg, err := json.Marshal(r)
+		if err != nil {
+			abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		readings = append(readings, reading{device: device, msg: msg, received: received})
+	}
+	for _, r := range readings {
+		m.recordAt(ctx.Request.Context(), r.device, r.msg, r.received)
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
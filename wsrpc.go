@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"math/rand/v2"
+
+	"github.com/finfinack/measure/data"
+
+	"github.com/gorilla/websocket"
+)
+
+// IDs of the requests sent to devices connected via websocket.
+const (
+	rpcGetStatus = iota + 1
+	rpcGetStatusAuth
+)
+
+var shellyPassword = flag.String("shellyPassword", "", "Password of Shelly devices with authentication enabled, used to answer their digest auth challenges on the websocket.")
+
+// wsRequestStatus asks a device connected via websocket for its full status so it does not have
+// to be awaited. Devices with authentication enabled answer with a challenge first.
+func (m *MeasureServer) wsRequestStatus(c *websocket.Conn, id int, auth *data.RPCAuth) error {
+	return c.WriteJSON(data.RPCRequest{
+		ID:     id,
+		Src:    serviceName,
+		Method: "Shelly.GetStatus",
+		Auth:   auth,
+	})
+}
+
+// wsResponse handles the response of a device to a request sent by wsRequestStatus.
+func (m *MeasureServer) wsResponse(ctx context.Context, c *websocket.Conn, message []byte) {
+	var resp data.RPCResponse
+	if err := json.Unmarshal(message, &resp); err != nil {
+		return
+	}
+	if resp.Error != nil {
+		if resp.Error.Code != data.RPCErrUnauthorized {
+			m.Logger.Debugf("request %d to %q failed: %s", resp.ID, resp.Src, resp.Error.Message)
+			return
+		}
+		switch {
+		case resp.ID != rpcGetStatus:
+			m.Logger.Warnf("authentication of %q failed, check -shellyPassword", resp.Src)
+		case *shellyPassword == "":
+			m.Logger.Warnf("%q requires authentication, set -shellyPassword", resp.Src)
+		default:
+			challenge, err := data.ParseAuthChallenge(resp.Error.Message)
+			if err != nil {
+				m.Logger.Warnf("unable to authenticate to %q: %s", resp.Src, err)
+				return
+			}
+			if err := m.wsRequestStatus(c, rpcGetStatusAuth, challenge.Answer(*shellyPassword, rand.Int64())); err != nil {
+				m.Logger.Warnf("unable to authenticate to %q: %s", resp.Src, err)
+			}
+		}
+		return
+	}
+
+	var status map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &status); err != nil || resp.Src == "" {
+		return
+	}
+	msg, err := fullStatus(resp.Src, status)
+	if err != nil {
+		return
+	}
+	m.record(ctx, resp.Src, msg)
+}
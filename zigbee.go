@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"strings"
+
+	"github.com/finfinack/measure/data"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var zigbee2mqttTopic = flag.String("zigbee2mqttTopic", "", "Base topic of zigbee2mqtt, usually zigbee2mqtt. Sensors publishing temperature or humidity are recorded with their friendly name as device ID. Empty disables zigbee2mqtt.")
+
+// zigbeeHandler returns an MQTT handler translating the state zigbee2mqtt publishes for every
+// device on <base>/<friendly name> into readings. Bridge messages and requests sent to devices
+// are ignored.
+func (m *MeasureServer) zigbeeHandler(base string) mqtt.MessageHandler {
+	return func(_ mqtt.Client, message mqtt.Message) {
+		device := strings.TrimPrefix(message.Topic(), base+"/")
+		if device == "bridge" || strings.HasPrefix(device, "bridge/") {
+			return
+		}
+		for _, suffix := range []string{"/set", "/get", "/availability"} {
+			if strings.HasSuffix(device, suffix) {
+				return
+			}
+		}
+
+		var state struct {
+			Temperature *float64 `json:"temperature"`
+			Humidity    *float64 `json:"humidity"`
+			Battery     *float64 `json:"battery"`
+		}
+		if err := json.Unmarshal(message.Payload(), &state); err != nil {
+			m.Logger.Debugf("ignoring non-JSON message on %q", message.Topic())
+			return
+		}
+		// Devices like switches or plugs report no measurements.
+		if state.Temperature == nil && state.Humidity == nil {
+			return
+		}
+		mqttMessages.WithLabelValues("zigbee2mqtt").Inc()
+
+		ctx, span := tracer.Start(context.Background(), "zigbee2mqtt", trace.WithAttributes(
+			attribute.String("device", device),
+			attribute.String("topic", message.Topic()),
+		))
+		defer span.End()
+		r := data.ReportStatus{
+			Device:      device,
+			Temperature: state.Temperature,
+			Humidity:    state.Humidity,
+			Battery:     state.Battery,
+		}
+		if err := r.Validate(); err != nil {
+			m.Logger.Warnf("dropping invalid reading of %q: %s", device, err)
+			return
+		}
+		status, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		m.record(ctx, device, json.RawMessage(status))
+	}
+}